@@ -32,5 +32,8 @@ func main() {
 	app.Action = func(c *cli.Context) {
 		plugin.RegisterDriver(driver.NewDriver("", ""))
 	}
+	app.Commands = []cli.Command{
+		resizeCommand,
+	}
 	app.Run(os.Args) // nolint
 }
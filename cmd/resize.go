@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/sacloud/docker-machine-sakuracloud/driver"
+	"github.com/urfave/cli"
+)
+
+var resizeCommand = cli.Command{
+	Name:      "resize",
+	Usage:     "Change the plan and expand the disk of the machine",
+	ArgsUsage: "MACHINE_NAME",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "storage-path, s",
+			Usage:  "Configures storage path",
+			EnvVar: "MACHINE_STORAGE_PATH",
+			Value:  defaultStoragePath(),
+		},
+		cli.IntFlag{Name: "core", Usage: "Number of CPU cores"},
+		cli.IntFlag{Name: "memory", Usage: "Size of memory(GB)"},
+		cli.IntFlag{Name: "gpu", Usage: "Number of GPUs(0 to change to a plan without GPU)"},
+		cli.StringFlag{Name: "commitment", Usage: "Commitment of the plan[standard/dedicatedcpu]"},
+		cli.IntFlag{Name: "plan-generation", Usage: "Generation of the plan[100/200]"},
+		cli.IntFlag{Name: "disk-size", Usage: "Size of the disk(GB) to expand to"},
+		cli.BoolFlag{Name: "expand-partition", Usage: "Expand the partition after expanding the disk"},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return errors.New("MACHINE_NAME is required")
+		}
+		if c.String("commitment") != "" && c.String("commitment") != "standard" && c.String("commitment") != "dedicatedcpu" {
+			return errors.New(`"--commitment" must be set to one of [standard/dedicatedcpu]`)
+		}
+		if c.Bool("expand-partition") && c.Int("disk-size") == 0 {
+			return errors.New(`"--disk-size" is required when "--expand-partition" is specified`)
+		}
+		opts := &driver.ResizeOptions{
+			Core:            c.Int("core"),
+			Memory:          c.Int("memory"),
+			Commitment:      c.String("commitment"),
+			PlanGeneration:  c.Int("plan-generation"),
+			DiskSize:        c.Int("disk-size"),
+			ExpandPartition: c.Bool("expand-partition"),
+		}
+		if c.IsSet("gpu") {
+			// "--gpu 0"でGPUプランから変更できるよう、指定された場合のみ反映する
+			gpu := c.Int("gpu")
+			opts.GPU = &gpu
+		}
+		return driver.ResizeMachine(c.String("storage-path"), c.Args().First(), opts)
+	},
+}
+
+// defaultStoragePath docker-machineのデフォルトのストレージパス(~/.docker/machine)
+func defaultStoragePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "machine")
+}
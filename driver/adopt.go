@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// prepareAdoption --sakuracloud-server-idで指定されたサーバの存在を確認し、SSHユーザーを決定する
+//
+// SSHユーザーはサーバのコピー元アーカイブから判断し、判断できない場合は--sakuracloud-os-typeに従う。
+func (d *Driver) prepareAdoption(ctx context.Context) error {
+	client := d.getClient()
+	id := types.StringID(d.serverConfig.ServerID)
+	if id.IsEmpty() {
+		return fmt.Errorf("invalid parameter: %q is invalid: %s", "--sakuracloud-server-id", d.serverConfig.ServerID)
+	}
+	if err := d.serverConfig.validateAdoption(); err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+
+	exists, err := client.IsExistsServer(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("invalid parameter: server[id:%d] is not exists", id)
+	}
+
+	user, err := client.DefaultUserName(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		user = d.serverConfig.SSHUserName()
+	}
+	d.SSHUser = user
+	return nil
+}
+
+// validateAdoption 既存のサーバを取り込む場合に指定できないオプションが指定されていないか検証する
+//
+// サーバやディスクの作成時にのみ利用するオプションは取り込み時には反映されないため、指定された場合はエラーとする。
+// 既定値を持つオプション(コア数やディスクサイズなど)は指定されたか判別できないため対象外。
+func (c *sakuraServerConfig) validateAdoption() error {
+	createOnly := []struct {
+		flag string
+		set  bool
+	}{
+		{"--sakuracloud-dry-run", c.DryRun},
+		{"--sakuracloud-source-archive-id", c.SourceArchiveID != ""},
+		{"--sakuracloud-source-disk-id", c.SourceDiskID != ""},
+		{"--sakuracloud-gpu", c.GPU > 0},
+		{"--sakuracloud-additional-disk", len(c.AdditionalDisks) > 0},
+		{"--sakuracloud-password", c.Password != ""},
+		{"--sakuracloud-enable-password-auth", c.EnablePWAuth},
+		{"--sakuracloud-packet-filter", c.PacketFilter != ""},
+		{"--sakuracloud-create-packet-filter", c.ManagedPacketFilter != nil},
+		{"--sakuracloud-tag", len(c.Tags) > 0},
+		{"--sakuracloud-description", c.Description != ""},
+		{"--sakuracloud-icon-id", c.IconID != ""},
+		{"--sakuracloud-keep-on-failure", c.KeepOnFailure},
+		{"--sakuracloud-startup-script", len(c.StartupScripts) > 0},
+		{"--sakuracloud-keep-startup-scripts", c.KeepStartupScripts},
+		{"--sakuracloud-ssh-key-id", len(c.SSHKeyIDs) > 0},
+		{"--sakuracloud-register-ssh-key", c.RegisterSSHKey},
+	}
+	for _, o := range createOnly {
+		if o.set {
+			return fmt.Errorf("%q cannot be used with %q", o.flag, "--sakuracloud-server-id")
+		}
+	}
+
+	// 取り込むサーバのNICは変更しないため、--sakuracloud-switch-idはprivate-onlyモードでのeth0のIPアドレスの指定にのみ利用できる
+	if len(c.additionalNICs()) > 0 {
+		return fmt.Errorf("%q cannot be used with %q except for eth0 in %q mode",
+			"--sakuracloud-switch-id", "--sakuracloud-server-id", "--sakuracloud-private-only")
+	}
+	return c.validatePrivateNICs()
+}
+
+// adopt 既存のサーバをマシンとして取り込む
+func (d *Driver) adopt(ctx context.Context, publicKey string) error {
+	client := d.getClient()
+	sv, err := client.ReadServer(ctx, types.StringID(d.serverConfig.ServerID))
+	if err != nil {
+		return fmt.Errorf("error reading server[id:%s]: %v", d.serverConfig.ServerID, err)
+	}
+	if len(sv.Disks) == 0 {
+		return fmt.Errorf("server[id:%s] has no disk", d.serverConfig.ServerID)
+	}
+
+	d.ID = sv.ID.String()
+	d.DiskID = sv.Disks[0].ID.String()
+	d.Adopted = true
+	if !d.PrivateOnly {
+		if len(sv.Interfaces) == 0 {
+			return fmt.Errorf("server[id:%s] has no NIC", d.ID)
+		}
+		ip := sv.Interfaces[0].IPAddress
+		if ip == "" {
+			ip = sv.Interfaces[0].UserIPAddress
+		}
+		if ip == "" {
+			return fmt.Errorf("IP address of server[id:%s] is not found", d.ID)
+		}
+		d.IPAddress = ip
+	}
+
+	if d.SSHKey == "" {
+		// 生成した鍵をディスクの修正機能で登録する、--sakuracloud-ssh-keyの鍵は登録済みとみなす
+		if err := d.installSSHKey(ctx, sv, publicKey); err != nil {
+			return err
+		}
+	} else if sv.InstanceStatus.IsUp() {
+		return nil
+	}
+
+	if err := client.PowerOn(ctx, d.ID); err != nil {
+		return err
+	}
+	return d.waitForServerByState(ctx, state.Running)
+}
+
+// installSSHKey ディスクの修正機能で公開鍵を登録する
+//
+// ディスクの修正にはサーバの停止が必要なため、起動中の場合は一度シャットダウンする。
+func (d *Driver) installSSHKey(ctx context.Context, sv *sacloud.Server, publicKey string) error {
+	client := d.getClient()
+	if sv.InstanceStatus.IsUp() {
+		log.Infof("Stopping server[id:%s] to install SSH public key...", d.ID)
+		if err := client.PowerOff(ctx, d.ID, false); err != nil {
+			return err
+		}
+		if err := d.waitForServerByState(ctx, state.Stopped); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Installing SSH public key into disk[id:%s]...", d.DiskID)
+	if err := client.EditDiskSSHKeys(ctx, sv.Disks[0].ID, publicKey); err != nil {
+		return fmt.Errorf("error installing SSH public key: %v", err)
+	}
+	return nil
+}
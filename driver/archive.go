@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+const (
+	defaultArchiveName = "{{.MachineName}}-{{.Timestamp}}"
+	archiveWaitTimeout = 2 * time.Hour // アーカイブのコピー完了を待つ時間
+)
+
+// archiveNameParams --sakuracloud-archive-nameのテンプレートで利用できる値
+type archiveNameParams struct {
+	MachineName string
+	ServerID    string
+	DiskID      string
+	Timestamp   string // 20060102-150405形式
+}
+
+func renderArchiveName(tmpl string, params *archiveNameParams) (string, error) {
+	t, err := template.New("archive-name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("%q is invalid: %s", "--sakuracloud-archive-name", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, params); err != nil {
+		return "", fmt.Errorf("%q is invalid: %s", "--sakuracloud-archive-name", err)
+	}
+	return buf.String(), nil
+}
+
+// archiveDisk 削除前にディスク(DiskID)からアーカイブを作成する
+//
+// ファイルシステムの整合性を保つため、サーバはシャットダウンしてからコピーする。
+// シャットダウンがタイムアウトした場合は強制停止する。
+func (d *Driver) archiveDisk(ctx context.Context, serverUp bool) error {
+	client := d.getClient()
+	diskID := types.StringID(d.DiskID)
+
+	if serverUp {
+		log.Infof("Shutting down server[id:%s] to archive the disk...", d.ID)
+		err := client.PowerOff(ctx, d.ID, false)
+		if err == nil {
+			err = d.waitForServerByState(ctx, state.Stopped)
+		}
+		if err != nil {
+			log.Warnf("Failed to shut down server[id:%s] gracefully, stopping it forcibly: %v", d.ID, err)
+			if err := client.PowerOff(ctx, d.ID, true); err != nil {
+				return fmt.Errorf("error stopping server[id:%s]: %v", d.ID, err)
+			}
+			if err := d.waitForServerByState(ctx, state.Stopped); err != nil {
+				return fmt.Errorf("error stopping server[id:%s]: %v", d.ID, err)
+			}
+		}
+	}
+
+	name, err := renderArchiveName(d.ArchiveName, &archiveNameParams{
+		MachineName: d.GetMachineName(),
+		ServerID:    d.ID,
+		DiskID:      d.DiskID,
+		Timestamp:   time.Now().Format("20060102-150405"),
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := append(types.Tags{}, d.ArchiveTags...)
+	tags = append(tags, machineTags(d.GetMachineName(), d.StorePath)...)
+
+	log.Infof("Creating archive %q from disk[id:%s], this may take a while...", name, diskID)
+	archiveID, err := client.CreateArchiveFromDisk(ctx, diskID, name,
+		fmt.Sprintf("archived by docker-machine before removing server[id:%s]", d.ID), tags, archiveWaitTimeout)
+	if err != nil {
+		return fmt.Errorf("error archiving disk[id:%s]: %v", diskID, err)
+	}
+	log.Infof("Created archive[id:%s] from disk[id:%s]", archiveID, diskID)
+	return nil
+}
@@ -0,0 +1,37 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderArchiveName(t *testing.T) {
+	params := &archiveNameParams{
+		MachineName: "sakura-dev",
+		ServerID:    "123456789012",
+		DiskID:      "234567890123",
+		Timestamp:   "20211008-150405",
+	}
+	cases := []struct {
+		tmpl   string
+		expect string
+		err    bool
+	}{
+		{tmpl: defaultArchiveName, expect: "sakura-dev-20211008-150405"},
+		{tmpl: "backup-{{.ServerID}}-{{.DiskID}}", expect: "backup-123456789012-234567890123"},
+		{tmpl: "fixed-name", expect: "fixed-name"},
+		{tmpl: "{{.NotExists}}", err: true},
+		{tmpl: "{{.MachineName", err: true},
+	}
+	for _, tc := range cases {
+		name, err := renderArchiveName(tc.tmpl, params)
+		if tc.err {
+			assert.Error(t, err, tc.tmpl)
+			continue
+		}
+		require.NoError(t, err, tc.tmpl)
+		assert.Equal(t, tc.expect, name)
+	}
+}
@@ -0,0 +1,196 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// bastionLocalAddress 踏み台ホスト経由で接続する際のトンネルの待ち受けアドレス
+const bastionLocalAddress = "127.0.0.1"
+
+// bastionDialTimeout 踏み台ホストへの接続のタイムアウト
+const bastionDialTimeout = 30 * time.Second
+
+// bastionTunnel 踏み台ホスト(SSH)を経由してマシンのプライベートIPアドレスへ転送するローカルのトンネル
+//
+// ドライバ(プラグイン)のプロセスが終了するまで待ち受ける
+type bastionTunnel struct {
+	mu             sync.Mutex
+	client         *ssh.Client
+	sshListener    net.Listener
+	engineListener net.Listener
+	// engineListened Docker Engine用の待ち受けを試みた場合にtrue
+	engineListened bool
+}
+
+// parseBastionKey --sakuracloud-bastion-keyの秘密鍵を読み込む
+func parseBastionKey(path string) (ssh.Signer, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%q is invalid: %s", "--sakuracloud-bastion-key", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%q is invalid: %s: %s", "--sakuracloud-bastion-key", path, err)
+	}
+	return signer, nil
+}
+
+// validateBastion 踏み台ホストの接続先/ユーザー/秘密鍵を検証する
+func (d *Driver) validateBastion() error {
+	if _, _, err := splitEndpoint(d.BastionHost, "--sakuracloud-bastion-host"); err != nil {
+		return err
+	}
+	if d.BastionUser == "" {
+		return fmt.Errorf("%q is required when %q is specified", "--sakuracloud-bastion-user", "--sakuracloud-bastion-host")
+	}
+	if d.BastionKey == "" {
+		return fmt.Errorf("%q is required when %q is specified", "--sakuracloud-bastion-key", "--sakuracloud-bastion-host")
+	}
+	_, err := parseBastionKey(d.BastionKey)
+	return err
+}
+
+// bastionClient 踏み台ホストへのSSH接続を返す、未接続の場合は接続する
+func (d *Driver) bastionClient() (*ssh.Client, error) {
+	d.bastion.mu.Lock()
+	defer d.bastion.mu.Unlock()
+	if d.bastion.client != nil {
+		return d.bastion.client, nil
+	}
+
+	host, port, err := splitEndpoint(d.BastionHost, "--sakuracloud-bastion-host")
+	if err != nil {
+		return nil, err
+	}
+	signer, err := parseBastionKey(d.BastionKey)
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User: d.BastionUser,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// マシンへのSSH接続(libmachine)と同様にホスト鍵は検証しない
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         bastionDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to bastion host %q: %s", d.BastionHost, err)
+	}
+	go func() {
+		// 踏み台ホストとの接続が切れた場合は次の転送時に再接続する
+		client.Wait() // nolint
+		d.bastion.mu.Lock()
+		defer d.bastion.mu.Unlock()
+		if d.bastion.client == client {
+			d.bastion.client = nil
+		}
+	}()
+	d.bastion.client = client
+	return client, nil
+}
+
+// bastionSSHPort マシンのSSH(22番ポート)へ転送するトンネルのポートを返す、未作成の場合は空いているポートで待ち受ける
+func (d *Driver) bastionSSHPort() (int, error) {
+	d.bastion.mu.Lock()
+	defer d.bastion.mu.Unlock()
+	if d.bastion.sshListener == nil {
+		listener, err := d.listenBastionTunnel(net.JoinHostPort(bastionLocalAddress, "0"), 22)
+		if err != nil {
+			return 0, err
+		}
+		d.bastion.sshListener = listener
+	}
+	return d.bastion.sshListener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// listenBastionEngine マシンのDocker Engineへ転送するトンネルを同じポート番号で待ち受ける
+//
+// docker-machine envなどで表示するURLを変えないため、ポートが利用中の場合は警告のみ表示する
+func (d *Driver) listenBastionEngine() {
+	d.bastion.mu.Lock()
+	defer d.bastion.mu.Unlock()
+	if d.bastion.engineListened {
+		return
+	}
+	d.bastion.engineListened = true
+
+	address := net.JoinHostPort(bastionLocalAddress, strconv.Itoa(d.EnginePort))
+	listener, err := d.listenBastionTunnel(address, d.EnginePort)
+	if err != nil {
+		log.Warnf("Failed to forward %s to the docker engine through bastion host %q: %s", address, d.BastionHost, err)
+		return
+	}
+	d.bastion.engineListener = listener
+}
+
+// listenBastionTunnel addressで待ち受け、受け付けた接続を踏み台ホスト経由でマシンのremotePortへ転送する
+func (d *Driver) listenBastionTunnel(address string, remotePort int) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := net.JoinHostPort(d.PrivateIPAddress, strconv.Itoa(remotePort))
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.forwardBastionConn(conn, remote)
+		}
+	}()
+	return listener, nil
+}
+
+func (d *Driver) forwardBastionConn(conn net.Conn, remote string) {
+	defer conn.Close()
+
+	client, err := d.bastionClient()
+	if err != nil {
+		log.Warnf("Failed to forward to %s: %s", remote, err)
+		return
+	}
+	remoteConn, err := client.Dial("tcp", remote)
+	if err != nil {
+		log.Warnf("Failed to forward to %s through bastion host %q: %s", remote, d.BastionHost, err)
+		return
+	}
+	defer remoteConn.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remoteConn, conn) // nolint
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, remoteConn) // nolint
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// closeBastionTunnel トンネルの待ち受けと踏み台ホストへの接続を終了する
+func (d *Driver) closeBastionTunnel() {
+	d.bastion.mu.Lock()
+	defer d.bastion.mu.Unlock()
+	for _, listener := range []net.Listener{d.bastion.sshListener, d.bastion.engineListener} {
+		if listener != nil {
+			listener.Close() // nolint
+		}
+	}
+	d.bastion.sshListener, d.bastion.engineListener, d.bastion.engineListened = nil, nil, false
+	if d.bastion.client != nil {
+		d.bastion.client.Close() // nolint
+		d.bastion.client = nil
+	}
+}
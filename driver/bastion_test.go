@@ -0,0 +1,376 @@
+package driver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	mcnssh "github.com/docker/machine/libmachine/ssh"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testBastionServer direct-tcpipによる転送のみを行うインプロセスのSSHサーバ
+//
+// 転送先のアドレスは記録し、実際にはポート番号ごとのtargetsへ転送する
+type testBastionServer struct {
+	addr    string
+	targets map[int]string
+
+	mu        sync.Mutex
+	requested []string
+}
+
+// newTestBastionServer userとauthorizedKeyの公開鍵のみ認証するSSHサーバを起動する
+func newTestBastionServer(t *testing.T, user, authorizedKey string, targets map[int]string) *testBastionServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+	publicKey, err := os.ReadFile(authorizedKey + ".pub")
+	require.NoError(t, err)
+	authorized, _, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == user && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized: %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() }) // nolint
+
+	server := &testBastionServer{addr: listener.Addr().String(), targets: targets}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (s *testBastionServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported") // nolint
+			continue
+		}
+		var payload struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error()) // nolint
+			continue
+		}
+		s.mu.Lock()
+		s.requested = append(s.requested, net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		s.mu.Unlock()
+
+		target, err := net.Dial("tcp", s.targets[int(payload.Port)])
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error()) // nolint
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			target.Close() // nolint
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			defer channel.Close()
+			defer target.Close()
+			go io.Copy(target, channel) // nolint
+			io.Copy(channel, target)    // nolint
+		}()
+	}
+}
+
+func (s *testBastionServer) requestedAddresses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requested...)
+}
+
+// newTestGreetingServer 接続ごとにgreetingを送信して切断するサーバを起動する
+func newTestGreetingServer(t *testing.T, greeting string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() }) // nolint
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(greeting)) // nolint
+			conn.Close()                 // nolint
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// freeLocalPort 空いているローカルのポート番号を返す
+func freeLocalPort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// generateTestKey テスト用のSSH鍵(秘密鍵と.pub)を作成しパスを返す
+func generateTestKey(t *testing.T, name string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, mcnssh.GenerateSSHKey(path))
+	return path
+}
+
+// readTunnel トンネルへ接続し受信した内容を返す
+func readTunnel(t *testing.T, port int) string {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(bastionLocalAddress, strconv.Itoa(port)), 5*time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Second)))
+	data, _ := io.ReadAll(conn)
+	return string(data)
+}
+
+func newBastionDriver(t *testing.T, server *testBastionServer, key string, enginePort int) *Driver {
+	d := NewDriver("fake-machine", t.TempDir()).(*Driver)
+	d.PrivateOnly = true
+	d.PrivateIPAddress = "192.168.0.11"
+	d.EnginePort = enginePort
+	d.BastionHost = server.addr
+	d.BastionUser = "bastion"
+	d.BastionKey = key
+	require.NoError(t, d.resolveEndpoint(context.Background()))
+	t.Cleanup(d.closeBastionTunnel)
+	return d
+}
+
+func TestDriver_BastionTunnel(t *testing.T) {
+	key := generateTestKey(t, "bastion")
+	enginePort := freeLocalPort(t)
+	server := newTestBastionServer(t, "bastion", key, map[int]string{
+		22:         newTestGreetingServer(t, "ssh"),
+		enginePort: newTestGreetingServer(t, "engine"),
+	})
+	d := newBastionDriver(t, server, key, enginePort)
+
+	host, err := d.GetSSHHostname()
+	require.NoError(t, err)
+	assert.Equal(t, bastionLocalAddress, host)
+
+	sshPort, err := d.GetSSHPort()
+	require.NoError(t, err)
+	assert.NotEqual(t, 22, sshPort)
+	assert.Equal(t, "ssh", readTunnel(t, sshPort))
+	// 同じトンネルを再利用する
+	port, err := d.GetSSHPort()
+	require.NoError(t, err)
+	assert.Equal(t, sshPort, port)
+	assert.Equal(t, "ssh", readTunnel(t, sshPort))
+
+	// Docker Engineへは--sakuracloud-engine-portと同じローカルのポートで転送する
+	url, err := d.GetURL()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("tcp://127.0.0.1:%d", enginePort), url)
+	assert.Equal(t, "engine", readTunnel(t, enginePort))
+
+	assert.Equal(t, []string{
+		"192.168.0.11:22",
+		"192.168.0.11:22",
+		fmt.Sprintf("192.168.0.11:%d", enginePort),
+	}, server.requestedAddresses())
+}
+
+func TestDriver_BastionTunnelUnauthorized(t *testing.T) {
+	key := generateTestKey(t, "bastion")
+	server := newTestBastionServer(t, "bastion", key, map[int]string{
+		22: newTestGreetingServer(t, "ssh"),
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		d := newBastionDriver(t, server, generateTestKey(t, "other"), freeLocalPort(t))
+		port, err := d.GetSSHPort()
+		require.NoError(t, err)
+		assert.Empty(t, readTunnel(t, port))
+	})
+	t.Run("other user", func(t *testing.T) {
+		d := newBastionDriver(t, server, key, freeLocalPort(t))
+		d.BastionUser = "root"
+		port, err := d.GetSSHPort()
+		require.NoError(t, err)
+		assert.Empty(t, readTunnel(t, port))
+	})
+	assert.Empty(t, server.requestedAddresses())
+	history := log.History()
+	require.NotEmpty(t, history)
+	assert.Contains(t, history[len(history)-1], "error connecting to bastion host")
+}
+
+func TestDriver_BastionEnginePortInUse(t *testing.T) {
+	key := generateTestKey(t, "bastion")
+	server := newTestBastionServer(t, "bastion", key, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	enginePort := listener.Addr().(*net.TCPAddr).Port
+	d := newBastionDriver(t, server, key, enginePort)
+
+	// 既存のトンネルなどでポートが利用中の場合もURLは変えない
+	url, err := d.GetURL()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("tcp://127.0.0.1:%d", enginePort), url)
+	history := log.History()
+	require.NotEmpty(t, history)
+	assert.Contains(t, history[len(history)-1], "Failed to forward")
+}
+
+func TestDriver_BastionFlags(t *testing.T) {
+	sw, err := fake.NewSwitchOp().Create(context.Background(), "is1a", &sacloud.SwitchCreateRequest{Name: "bastion"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, fake.NewSwitchOp().Delete(context.Background(), "is1a", sw.ID))
+	})
+	key := generateTestKey(t, "bastion")
+
+	privateOnly := func(flags map[string]interface{}) map[string]interface{} {
+		values := map[string]interface{}{
+			"sakuracloud-switch-id":    []string{sw.ID.String()},
+			"sakuracloud-private-ip":   []string{"192.168.0.11"},
+			"sakuracloud-private-only": true,
+			"sakuracloud-gateway":      "192.168.0.1",
+		}
+		for k, v := range flags {
+			values[k] = v
+		}
+		return values
+	}
+
+	d := newFakeDriver(t, privateOnly(map[string]interface{}{
+		"sakuracloud-bastion-host": "203.0.113.11:10022",
+		"sakuracloud-bastion-user": "bastion",
+		"sakuracloud-bastion-key":  key,
+	}))
+	assert.Equal(t, "203.0.113.11:10022", d.BastionHost)
+	assert.Equal(t, "bastion", d.BastionUser)
+	assert.Equal(t, key, d.BastionKey)
+	ip, err := d.GetIP()
+	require.NoError(t, err)
+	assert.Equal(t, bastionLocalAddress, ip)
+
+	cases := []struct {
+		name  string
+		flags map[string]interface{}
+		err   string
+	}{
+		{
+			name: "without user",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host": "203.0.113.11",
+				"sakuracloud-bastion-key":  key,
+			},
+			err: `"--sakuracloud-bastion-user" is required`,
+		},
+		{
+			name: "without key",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host": "203.0.113.11",
+				"sakuracloud-bastion-user": "bastion",
+			},
+			err: `"--sakuracloud-bastion-key" is required`,
+		},
+		{
+			name: "nonexistent key",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host": "203.0.113.11",
+				"sakuracloud-bastion-user": "bastion",
+				"sakuracloud-bastion-key":  filepath.Join(t.TempDir(), "nonexistent"),
+			},
+			err: `"--sakuracloud-bastion-key" is invalid`,
+		},
+		{
+			name: "public key",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host": "203.0.113.11",
+				"sakuracloud-bastion-user": "bastion",
+				"sakuracloud-bastion-key":  key + ".pub",
+			},
+			err: `"--sakuracloud-bastion-key" is invalid`,
+		},
+		{
+			name: "invalid host",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host": "203.0.113.11:0",
+				"sakuracloud-bastion-user": "bastion",
+				"sakuracloud-bastion-key":  key,
+			},
+			err: `"--sakuracloud-bastion-host" is invalid`,
+		},
+		{
+			name: "with forward endpoint",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host":     "203.0.113.11",
+				"sakuracloud-bastion-user":     "bastion",
+				"sakuracloud-bastion-key":      key,
+				"sakuracloud-forward-endpoint": "203.0.113.12:10022",
+			},
+			err: "can't be specified with",
+		},
+		{
+			name: "with vpc router",
+			flags: map[string]interface{}{
+				"sakuracloud-bastion-host":  "203.0.113.11",
+				"sakuracloud-bastion-user":  "bastion",
+				"sakuracloud-bastion-key":   key,
+				"sakuracloud-vpc-router-id": "123456789012",
+			},
+			err: "can't be specified with",
+		},
+		{
+			name:  "without endpoint",
+			flags: map[string]interface{}{},
+			err:   `"--sakuracloud-bastion-host" is required`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := setFakeDriverFlags(t, privateOnly(tc.flags))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
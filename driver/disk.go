@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/sacloud/docker-machine-sakuracloud/sakuracloud"
+	diskBuilder "github.com/sacloud/libsacloud/v2/helper/builder/disk"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// maxAdditionalDisks サーバに追加可能なディスクの最大数
+const maxAdditionalDisks = 3
+
+// additionalDiskConfig 追加ディスク(データディスク)の設定
+type additionalDiskConfig struct {
+	Plan       string
+	Size       int
+	Connection string
+}
+
+// parseAdditionalDisk "plan=ssd,size=100,connection=virtio"の形式の値をパースする
+func parseAdditionalDisk(v string) (*additionalDiskConfig, error) {
+	disk := &additionalDiskConfig{
+		Plan:       defaultDiskPlan,
+		Size:       defaultDiskSize,
+		Connection: defaultDiskConnection,
+	}
+	for _, kv := range strings.Split(v, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("%q is invalid: %s", "--sakuracloud-additional-disk", v)
+		}
+		switch pair[0] {
+		case "plan":
+			disk.Plan = pair[1]
+		case "size":
+			size, err := strconv.Atoi(pair[1])
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("%q is invalid: %s", "--sakuracloud-additional-disk", v)
+			}
+			disk.Size = size
+		case "connection":
+			disk.Connection = pair[1]
+		default:
+			return nil, fmt.Errorf("%q has unknown key %q: %s", "--sakuracloud-additional-disk", pair[0], v)
+		}
+	}
+	return disk, nil
+}
+
+func parseAdditionalDisks(values []string) ([]*additionalDiskConfig, error) {
+	var disks []*additionalDiskConfig
+	for _, v := range values {
+		disk, err := parseAdditionalDisk(v)
+		if err != nil {
+			return nil, err
+		}
+		disks = append(disks, disk)
+	}
+	return disks, nil
+}
+
+// validateDiskSize ゾーンのディスクプランで利用可能なサイズであるか検証する
+//
+// ディスクプランAPIが利用できない場合は静的なサイズの一覧(allowSSDSizes/allowHDDSizes)で検証する。
+func (c *sakuraServerConfig) validateDiskSize(ctx context.Context, client *sakuracloud.APIClient, plan string, size int, flagName string) error {
+	sizes, err := client.AvailableDiskSizes(ctx, diskPlanID(plan))
+	switch {
+	case err != nil:
+		log.Warnf("Failed to read disk plan %q in zone %q, falling back to built-in disk sizes: %v", plan, client.Zone, err)
+		sizes = allowDiskSizes(plan)
+	case len(sizes) == 0:
+		log.Warnf("Disk plan %q in zone %q has no available sizes, falling back to built-in disk sizes", plan, client.Zone)
+		sizes = allowDiskSizes(plan)
+	}
+
+	if c.isIntInValue(size, sizes...) {
+		return nil
+	}
+
+	var strSizes []string
+	for _, s := range sizes {
+		strSizes = append(strSizes, strconv.Itoa(s))
+	}
+	return fmt.Errorf("%q must be set to one of [%s] for disk plan %q in zone %q",
+		flagName, strings.Join(strSizes, "/"), plan, client.Zone)
+}
+
+func diskPlanID(plan string) types.ID {
+	switch plan {
+	case "ssd":
+		return types.DiskPlans.SSD
+	case "hdd":
+		return types.DiskPlans.HDD
+	}
+	return types.ID(0)
+}
+
+func diskConnection(connection string) types.EDiskConnection {
+	switch connection {
+	case "virtio":
+		return types.DiskConnections.VirtIO
+	case "ide":
+		return types.DiskConnections.IDE
+	}
+	return types.EDiskConnection("")
+}
+
+func (d *Driver) buildAdditionalDiskSpecs() []diskBuilder.Builder {
+	var builders []diskBuilder.Builder
+	for i, disk := range d.serverConfig.AdditionalDisks {
+		builders = append(builders, &diskBuilder.BlankBuilder{
+			Name:        fmt.Sprintf("%s-data%d", d.serverConfig.HostName, i+1),
+			SizeGB:      disk.Size,
+			PlanID:      diskPlanID(disk.Plan),
+			Connection:  diskConnection(disk.Connection),
+			Description: d.serverConfig.Description,
+			Tags:        d.serverConfig.resourceTags(),
+			IconID:      types.StringID(d.serverConfig.IconID),
+			Client:      d.Client.DiskBuilderClient(),
+		})
+	}
+	return builders
+}
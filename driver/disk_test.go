@@ -0,0 +1,65 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAdditionalDisk(t *testing.T) {
+	cases := []struct {
+		value  string
+		expect *additionalDiskConfig
+		err    bool
+	}{
+		{
+			value:  "",
+			expect: &additionalDiskConfig{Plan: defaultDiskPlan, Size: defaultDiskSize, Connection: defaultDiskConnection},
+		},
+		{
+			value:  "size=100",
+			expect: &additionalDiskConfig{Plan: defaultDiskPlan, Size: 100, Connection: defaultDiskConnection},
+		},
+		{
+			value:  "plan=hdd, size=2048 ,connection=ide",
+			expect: &additionalDiskConfig{Plan: "hdd", Size: 2048, Connection: "ide"},
+		},
+		{value: "type=ssd", err: true},
+		{value: "size=100gb", err: true},
+		{value: "size=0", err: true},
+		{value: "size", err: true},
+	}
+	for _, tc := range cases {
+		disk, err := parseAdditionalDisk(tc.value)
+		if tc.err {
+			assert.Error(t, err, tc.value)
+			continue
+		}
+		require.NoError(t, err, tc.value)
+		assert.Equal(t, tc.expect, disk, tc.value)
+	}
+}
+
+func TestValidateAdditionalDisks(t *testing.T) {
+	config := *defaultServerConfig
+	config.OSType = defaultOSType
+	config.Commitment = defaultCommitment
+	config.DiskConnection = defaultDiskConnection
+	config.InterfaceDriver = defaultInterfaceDriver
+
+	disks, err := parseAdditionalDisks([]string{"size=20", "size=40", "size=100"})
+	require.NoError(t, err)
+	config.AdditionalDisks = disks
+	assert.NoError(t, config.Validate())
+
+	disks, err = parseAdditionalDisks([]string{"size=20", "size=40", "size=100", "size=250"})
+	require.NoError(t, err)
+	config.AdditionalDisks = disks
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "up to 3 times")
+
+	config.AdditionalDisks = []*additionalDiskConfig{{Plan: "nvme", Size: 20, Connection: defaultDiskConnection}}
+	assert.Error(t, config.Validate())
+}
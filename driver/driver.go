@@ -8,7 +8,7 @@ import (
 	"net"
 	"os"
 	"strconv"
-	"time"
+	"sync"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
@@ -19,7 +19,7 @@ import (
 	"github.com/sacloud/docker-machine-sakuracloud/sakuracloud"
 	diskBuilder "github.com/sacloud/libsacloud/v2/helper/builder/disk"
 	"github.com/sacloud/libsacloud/v2/helper/builder/server"
-	"github.com/sacloud/libsacloud/v2/sacloud/ostype"
+	"github.com/sacloud/libsacloud/v2/sacloud"
 	"github.com/sacloud/libsacloud/v2/sacloud/types"
 )
 
@@ -28,10 +28,49 @@ type Driver struct {
 	*drivers.BaseDriver
 	serverConfig *sakuraServerConfig
 	Client       *sakuracloud.APIClient
-	ID           string
-	DiskID       string
-	EnginePort   int
-	SSHKey       string
+	// clientWarnOnce 認証情報の読み込みエラーを一度だけ表示するため
+	clientWarnOnce sync.Once
+	ID             string
+	DiskID         string
+	// AdditionalDiskIDs IDs of the blank disks created by --sakuracloud-additional-disk
+	AdditionalDiskIDs []string
+	EnginePort        int
+	SSHKey            string
+
+	// PacketFilterID ID of the packet filter created by the driver
+	PacketFilterID string
+
+	// SSHKeyResourceID ID of the SSH key registered by --sakuracloud-register-ssh-key
+	SSHKeyResourceID string
+
+	// WaitTimeout/WaitInterval seconds for waiting server state
+	WaitTimeout  int
+	WaitInterval int
+
+	RestartWithReset bool
+
+	// for archiving the disk before removal
+	ArchiveOnRemove bool
+	ArchiveName     string
+	ArchiveTags     []string
+
+	// Adopted true if the server was not created by the driver but specified with --sakuracloud-server-id
+	Adopted bool
+
+	// for private-only mode
+	PrivateOnly      bool
+	PrivateIPAddress string
+	VPCRouterID      string
+	ForwardEndpoint  string
+
+	// for private-only mode through a bastion host
+	BastionHost string
+	BastionUser string
+	BastionKey  string
+	bastion     bastionTunnel
+
+	// runSSHCommand サーバ上でコマンドを実行する、テストでは置き換える
+	runSSHCommand func(d drivers.Driver, command string) (string, error)
 }
 
 // GetCreateFlags create flags
@@ -49,18 +88,31 @@ func NewDriver(hostName, storePath string) drivers.Driver {
 		Client:       &sakuracloud.APIClient{},
 		serverConfig: defaultServerConfig,
 		EnginePort:   defaultServerConfig.EnginePort,
+		WaitTimeout:  defaultWaitTimeout,
+		WaitInterval: defaultWaitInterval,
+
+		runSSHCommand: drivers.RunSSHCommandFromDriver,
 	}
 }
 
-func validateSakuraServerConfig(c *sakuracloud.APIClient, config *sakuraServerConfig) error {
+func validateSakuraServerConfig(ctx context.Context, c *sakuracloud.APIClient, config *sakuraServerConfig) error {
 	err := config.Validate()
 	if err != nil {
 		return fmt.Errorf("invalid parameter: %s", err)
 	}
 
-	res, err := c.IsValidPlan(config.Core, config.Memory, config.GPU)
+	res, err := c.IsValidPlan(ctx, config.Core, config.Memory, config.GPU, config.commitment(), config.planGeneration())
 	if !res || err != nil {
-		return fmt.Errorf("invalid parameter: invalid plan: core/memory/gpu : %v", err)
+		return fmt.Errorf("invalid parameter: invalid plan: core/memory/gpu/commitment/generation : %v", err)
+	}
+
+	if err := config.validateDiskSize(ctx, c, config.DiskPlan, config.DiskSize, "--sakuracloud-disk-size"); err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+	for _, disk := range config.AdditionalDisks {
+		if err := config.validateDiskSize(ctx, c, disk.Plan, disk.Size, "--sakuracloud-additional-disk"); err != nil {
+			return fmt.Errorf("invalid parameter: %s", err)
+		}
 	}
 
 	if config.PacketFilter != "" {
@@ -68,7 +120,7 @@ func validateSakuraServerConfig(c *sakuracloud.APIClient, config *sakuraServerCo
 		if id.IsEmpty() {
 			return fmt.Errorf("invalid parameter: invalid packet-filter-id")
 		}
-		exists, err := c.IsExistsPacketFilter(id)
+		exists, err := c.IsExistsPacketFilter(ctx, id)
 		if err != nil {
 			return err
 		}
@@ -77,6 +129,64 @@ func validateSakuraServerConfig(c *sakuracloud.APIClient, config *sakuraServerCo
 		}
 	}
 
+	for _, strID := range config.SSHKeyIDs {
+		id := types.StringID(strID)
+		if id.IsEmpty() {
+			return fmt.Errorf("invalid parameter: %q is invalid: %s", "--sakuracloud-ssh-key-id", strID)
+		}
+		exists, err := c.IsExistsSSHKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("invalid parameter: ssh-key[id:%d] is not exists", id)
+		}
+	}
+
+	if config.SourceArchiveID != "" {
+		id := types.StringID(config.SourceArchiveID)
+		exists, err := c.IsExistsArchive(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("invalid parameter: archive[id:%d] is not exists", id)
+		}
+	}
+
+	if config.SourceDiskID != "" {
+		id := types.StringID(config.SourceDiskID)
+		exists, err := c.IsExistsDisk(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("invalid parameter: disk[id:%d] is not exists", id)
+		}
+	}
+
+	if err := resolveArchive(ctx, c, config); err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+	if err := config.validateResolved(); err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+
+	if err := resolveStartupScriptNotes(ctx, c, config); err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+
+	for _, nic := range config.PrivateNICs {
+		id := types.StringID(nic.SwitchID)
+		exists, err := c.IsExistsSwitch(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("invalid parameter: switch[id:%d] is not exists", id)
+		}
+	}
+
 	return nil
 }
 
@@ -84,15 +194,28 @@ func validateSakuraServerConfig(c *sakuracloud.APIClient, config *sakuraServerCo
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	// API Client
 	d.Client = sakuracloud.NewAPIClient(
-		flags.String("sakuracloud-access-token"),
-		flags.String("sakuracloud-access-token-secret"),
+		credentialReference("--sakuracloud-access-token", "SAKURACLOUD_ACCESS_TOKEN", flags.String("sakuracloud-access-token")),
+		credentialReference("--sakuracloud-access-token-secret", "SAKURACLOUD_ACCESS_TOKEN_SECRET", flags.String("sakuracloud-access-token-secret")),
 		flags.String("sakuracloud-zone"),
-		flags.String("sakuracloud-password"),
 	)
-	if err := d.getClient().ValidateClientConfig(); err != nil {
+	d.Client.Profile = flags.String("sakuracloud-profile")
+	d.Client.APIRootURL = flags.String("sakuracloud-api-root-url")
+	if err := d.Client.LoadProfile(); err != nil {
+		return err
+	}
+	if d.Client.Zone == "" {
+		d.Client.Zone = defaultRegion
+	}
+	if err := d.Client.ValidateClientConfig(); err != nil {
 		return err
 	}
 
+	// 参照形式で指定されたパスワードはここで読み込み、config storeへは残さない
+	password, err := sakuracloud.ResolveSecret(flags.String("sakuracloud-password"))
+	if err != nil {
+		return fmt.Errorf("resolving %q is failed: %s", "--sakuracloud-password", err)
+	}
+
 	// Swarm(legacy swarm)
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
@@ -104,32 +227,143 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		Core:            flags.Int("sakuracloud-core"),
 		Memory:          flags.Int("sakuracloud-memory"),
 		GPU:             flags.Int("sakuracloud-gpu"),
+		Commitment:      flags.String("sakuracloud-commitment"),
+		PlanGeneration:  flags.Int("sakuracloud-plan-generation"),
 		DiskPlan:        flags.String("sakuracloud-disk-plan"),
 		DiskSize:        flags.Int("sakuracloud-disk-size"),
 		DiskConnection:  flags.String("sakuracloud-disk-connection"),
 		InterfaceDriver: flags.String("sakuracloud-interface-driver"),
-		Password:        flags.String("sakuracloud-password"),
+		Password:        password,
 		PacketFilter:    flags.String("sakuracloud-packet-filter"),
 		EnablePWAuth:    flags.Bool("sakuracloud-enable-password-auth"),
+		SourceArchiveID: flags.String("sakuracloud-source-archive-id"),
+		SourceDiskID:    flags.String("sakuracloud-source-disk-id"),
+		Tags:            flags.StringSlice("sakuracloud-tag"),
+		MachineTags:     machineTags(d.GetMachineName(), d.StorePath),
+		Description:     flags.String("sakuracloud-description"),
+		IconID:          flags.String("sakuracloud-icon-id"),
+		ServerID:        flags.String("sakuracloud-server-id"),
 	}
 
 	if d.serverConfig.HostName == "" {
 		d.serverConfig.HostName = d.GetMachineName()
 	}
 
+	privateNICs, err := parsePrivateNICs(
+		flags.StringSlice("sakuracloud-switch-id"),
+		flags.StringSlice("sakuracloud-private-ip"),
+		flags.StringSlice("sakuracloud-private-netmask"),
+	)
+	if err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+	d.serverConfig.PrivateNICs = privateNICs
+
+	additionalDisks, err := parseAdditionalDisks(flags.StringSlice("sakuracloud-additional-disk"))
+	if err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+	d.serverConfig.AdditionalDisks = additionalDisks
+	if flags.Bool("sakuracloud-create-packet-filter") {
+		d.serverConfig.ManagedPacketFilter = &packetFilterConfig{
+			AllowPorts: flags.StringSlice("sakuracloud-packet-filter-allow-port"),
+			Sources:    flags.StringSlice("sakuracloud-packet-filter-source"),
+		}
+	}
+	d.serverConfig.KeepOnFailure = flags.Bool("sakuracloud-keep-on-failure")
+	d.serverConfig.DryRun = flags.Bool("sakuracloud-dry-run")
+	d.serverConfig.PrivateOnly = flags.Bool("sakuracloud-private-only")
+	d.serverConfig.Gateway = flags.String("sakuracloud-gateway")
+
 	// for SSH
-	d.SSHUser = d.serverConfig.SSHUserName()
 	d.SSHPort = 22
 	d.SSHKey = flags.String("sakuracloud-ssh-key")
+	d.serverConfig.SSHKeyIDs = flags.StringSlice("sakuracloud-ssh-key-id")
+	d.serverConfig.RegisterSSHKey = flags.Bool("sakuracloud-register-ssh-key")
 
 	// for docker engine port
 	d.EnginePort = flags.Int("sakuracloud-engine-port")
 
-	return validateSakuraServerConfig(d.Client, d.serverConfig)
+	// startup scripts
+	scriptParams := &startupScriptParams{
+		MachineName: d.GetMachineName(),
+		EnginePort:  d.EnginePort,
+	}
+	if len(d.serverConfig.PrivateNICs) > 0 {
+		scriptParams.PrivateIPAddress = d.serverConfig.PrivateNICs[0].IPAddress
+	}
+	startupScripts, err := parseStartupScripts(flags.StringSlice("sakuracloud-startup-script"), scriptParams)
+	if err != nil {
+		return fmt.Errorf("invalid parameter: %s", err)
+	}
+	d.serverConfig.StartupScripts = startupScripts
+	d.serverConfig.KeepStartupScripts = flags.Bool("sakuracloud-keep-startup-scripts")
+	d.serverConfig.StartupScriptTimeout = flags.Int("sakuracloud-startup-script-timeout")
+	if d.serverConfig.StartupScriptTimeout <= 0 {
+		return fmt.Errorf("invalid parameter: %q must be greater than 0", "--sakuracloud-startup-script-timeout")
+	}
+
+	// for waiting server state
+	d.WaitTimeout = flags.Int("sakuracloud-wait-timeout")
+	d.WaitInterval = flags.Int("sakuracloud-wait-interval")
+	if d.WaitTimeout <= 0 || d.WaitInterval <= 0 {
+		return fmt.Errorf("invalid parameter: %q and %q must be greater than 0", "--sakuracloud-wait-timeout", "--sakuracloud-wait-interval")
+	}
+	d.RestartWithReset = flags.Bool("sakuracloud-restart-with-reset")
+
+	d.ArchiveOnRemove = flags.Bool("sakuracloud-archive-on-remove")
+	d.ArchiveName = flags.String("sakuracloud-archive-name")
+	d.ArchiveTags = flags.StringSlice("sakuracloud-archive-tag")
+	if d.ArchiveOnRemove {
+		if _, err := renderArchiveName(d.ArchiveName, &archiveNameParams{}); err != nil {
+			return fmt.Errorf("invalid parameter: %s", err)
+		}
+	}
+
+	ctx := context.Background()
+	if d.serverConfig.ServerID != "" {
+		// 既存のサーバを取り込む場合はサーバ作成用のパラメータは検証しない
+		if err := d.prepareAdoption(ctx); err != nil {
+			return err
+		}
+	} else {
+		if err := validateSakuraServerConfig(ctx, d.Client, d.serverConfig); err != nil {
+			return err
+		}
+		// os-typeにアーカイブ名/タグを指定した場合はアーカイブの検索後にSSHユーザーが決まる
+		d.SSHUser = d.serverConfig.SSHUserName()
+	}
+
+	// for private-only mode
+	if nic := d.serverConfig.primaryNIC(); nic != nil {
+		d.PrivateOnly = true
+		d.PrivateIPAddress = nic.IPAddress
+		d.VPCRouterID = flags.String("sakuracloud-vpc-router-id")
+		d.ForwardEndpoint = flags.String("sakuracloud-forward-endpoint")
+		d.BastionHost = flags.String("sakuracloud-bastion-host")
+		d.BastionUser = flags.String("sakuracloud-bastion-user")
+		d.BastionKey = flags.String("sakuracloud-bastion-key")
+		if d.VPCRouterID == "" && d.ForwardEndpoint == "" && d.BastionHost == "" {
+			return fmt.Errorf("invalid parameter: %q, %q or %q is required when %q is specified",
+				"--sakuracloud-vpc-router-id", "--sakuracloud-forward-endpoint", "--sakuracloud-bastion-host", "--sakuracloud-private-only")
+		}
+		if d.BastionHost != "" && (d.VPCRouterID != "" || d.ForwardEndpoint != "") {
+			return fmt.Errorf("invalid parameter: %q can't be specified with %q or %q",
+				"--sakuracloud-bastion-host", "--sakuracloud-vpc-router-id", "--sakuracloud-forward-endpoint")
+		}
+		if err := d.resolveEndpoint(ctx); err != nil {
+			return fmt.Errorf("invalid parameter: %s", err)
+		}
+	}
+	return nil
 }
 
 func (d *Driver) getClient() *sakuracloud.APIClient {
-	d.Client.Init()
+	if err := d.Client.Init(); err != nil {
+		d.clientWarnOnce.Do(func() {
+			log.Warnf("Failed to load credentials: %s", err)
+		})
+	}
 	return d.Client
 }
 
@@ -147,6 +381,9 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
+	if d.BastionHost != "" {
+		d.listenBastionEngine()
+	}
 	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(d.EnginePort))), nil
 }
 
@@ -155,33 +392,44 @@ func (d *Driver) GetSSHHostname() (string, error) {
 	return d.GetIP()
 }
 
+// GetSSHPort return ssh port
+func (d *Driver) GetSSHPort() (int, error) {
+	if d.BastionHost != "" {
+		return d.bastionSSHPort()
+	}
+	return d.BaseDriver.GetSSHPort()
+}
+
 // GetIP return public or private ip address
 func (d *Driver) GetIP() (string, error) {
 	if d.IPAddress != "" {
 		return d.IPAddress, nil
 	}
 
-	return d.getClient().GetIP(d.ID)
+	ctx := context.Background()
+	if d.PrivateOnly {
+		if err := d.resolveEndpoint(ctx); err != nil {
+			return "", err
+		}
+		return d.IPAddress, nil
+	}
+	return d.getClient().GetIP(ctx, d.ID)
 }
 
 // GetState get server power state
 func (d *Driver) GetState() (state.State, error) {
-	s, err := d.getClient().State(d.ID)
+	return d.getState(context.Background())
+}
+
+func (d *Driver) getState(ctx context.Context) (state.State, error) {
+	status, availability, err := d.getClient().State(ctx, d.ID)
 	if err != nil {
+		if sacloud.IsNotFoundError(err) {
+			return state.Error, &serverNotFoundError{ID: d.ID}
+		}
 		return state.None, err
 	}
-	var vmState state.State
-	switch s {
-	case "up":
-		vmState = state.Running
-	case "cleaning":
-		vmState = state.Stopping
-	case "down":
-		vmState = state.Stopped
-	default:
-		vmState = state.None
-	}
-	return vmState, nil
+	return serverState(d.ID, status, availability)
 }
 
 // PreCreateCheck check before create
@@ -195,6 +443,10 @@ func (d *Driver) PreCreateCheck() error {
 			return fmt.Errorf("Ssh public key does not exist: %q", d.SSHKey+".pub")
 		}
 	}
+
+	if d.serverConfig.DryRun {
+		return d.dryRun(context.Background())
+	}
 	return nil
 }
 
@@ -204,12 +456,46 @@ func (d *Driver) Create() error {
 	if err != nil {
 		return err
 	}
-	d.preparePassword()
 
-	// build server
 	ctx := context.Background()
+	if d.serverConfig.ServerID != "" {
+		return d.adopt(ctx, publicKey)
+	}
+
+	if err := d.preparePassword(); err != nil {
+		return err
+	}
+	created := &createdResources{}
+	if err := d.create(ctx, publicKey, created); err != nil {
+		if rbErr := d.rollback(ctx, created); rbErr != nil {
+			log.Error(rbErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *Driver) create(ctx context.Context, publicKey string, created *createdResources) error {
+	if d.serverConfig.ManagedPacketFilter != nil {
+		if err := d.createPacketFilter(ctx); err != nil {
+			return err
+		}
+		created.PacketFilterID = d.PacketFilterID
+	}
+	if d.serverConfig.RegisterSSHKey {
+		if err := d.registerSSHKey(ctx, publicKey); err != nil {
+			return err
+		}
+		created.SSHKeyID = d.SSHKeyResourceID
+	}
+
+	// build server
 	sb := d.buildSakuraServerSpec(publicKey)
 	buildResult, err := sb.Build(ctx, d.Client.Zone)
+	if buildResult != nil {
+		created.ServerID = buildResult.ServerID
+		created.DiskIDs = buildResult.DiskIDs
+	}
 	if err != nil {
 		return fmt.Errorf("error creating host: %v", err)
 	}
@@ -222,15 +508,24 @@ func (d *Driver) Create() error {
 
 	d.ID = sv.ID.String()
 	d.DiskID = sv.Disks[0].ID.String()
-	d.IPAddress = sv.Interfaces[0].IPAddress
+	for _, id := range buildResult.DiskIDs[1:] {
+		d.AdditionalDiskIDs = append(d.AdditionalDiskIDs, id.String())
+	}
+	if !d.PrivateOnly {
+		d.IPAddress = sv.Interfaces[0].IPAddress
+	}
 
 	if d.serverConfig.IsNeedWaitingRestart() {
 		// wait for shutdown
-		d.waitForServerByState(state.Stopped)
-		if err := d.Start(); err != nil {
+		if err := d.waitForStartupScripts(ctx); err != nil {
+			return err
+		}
+		if err := d.getClient().PowerOn(ctx, d.ID); err != nil {
+			return err
+		}
+		if err := d.waitForServerByState(ctx, state.Running); err != nil {
 			return err
 		}
-		d.waitForServerByState(state.Running)
 	}
 
 	return nil
@@ -265,12 +560,36 @@ func (d *Driver) prepareSSHKey() (string, error) {
 	return string(pKey), nil
 }
 
-func (d *Driver) preparePassword() {
-	if d.serverConfig.Password == "" {
-		d.Client.Password = generateRandomPassword()
-		log.Infof("password is not set, generated.[password:%s]", d.Client.Password)
-		d.serverConfig.Password = d.Client.Password
+// credentialReference config storeへ保存するアクセストークン/シークレットの値を返す
+//
+// 環境変数から指定された値はenv:形式の参照として保存し、平文のまま保存する場合は警告する
+func credentialReference(flagName, envName, value string) string {
+	if value == "" || sakuracloud.IsSecretReference(value) {
+		return value
+	}
+	if os.Getenv(envName) == value {
+		return "env:" + envName
 	}
+	log.Warnf("%s is saved to the machine config in plain text, use the reference form(env:/file:/keyring:/cmd:) to avoid it", flagName)
+	return value
+}
+
+// preparePassword パスワードが未指定の場合は生成し、SSH鍵と同じディレクトリのファイルへ保存する
+func (d *Driver) preparePassword() error {
+	if d.serverConfig.Password != "" {
+		return nil
+	}
+	password := generateRandomPassword()
+	if err := os.WriteFile(d.passwordPath(), []byte(password+"\n"), 0600); err != nil {
+		return fmt.Errorf("error saving generated password: %v", err)
+	}
+	log.Infof("password is not set, generated and saved to %s", d.passwordPath())
+	d.serverConfig.Password = password
+	return nil
+}
+
+func (d *Driver) passwordPath() string {
+	return d.ResolveStorePath("password")
 }
 
 func generateRandomPassword() string {
@@ -281,24 +600,6 @@ func generateRandomPassword() string {
 	return strconv.FormatUint(n, 36)
 }
 
-func (d *Driver) waitForServerByState(waitForState state.State) {
-	log.Infof("Waiting for server to become %v", waitForState)
-	for {
-		s, err := d.GetState()
-		if err != nil {
-			log.Debugf("Failed to get Server State - %+v", err)
-			continue
-		}
-
-		if s == waitForState {
-			break
-		} else {
-			log.Debugf("Still waiting - state is %s...", s)
-		}
-		time.Sleep(5 * time.Second)
-	}
-}
-
 const sakuraAllowSudoScriptBody = `#!/bin/bash
 # @sacloud-once
 # @sacloud-desc ubuntuユーザーがsudo出来るように/etc/sudoersを編集します
@@ -307,6 +608,11 @@ const sakuraAllowSudoScriptBody = `#!/bin/bash
 # @sacloud-require-archive distro-ubuntu
 export DEBIAN_FRONTEND=noninteractive
 echo "ubuntu ALL=(ALL) NOPASSWD:ALL" >> /etc/sudoers || exit 1
+exit 0`
+
+const sakuraShutdownScriptBody = `#!/bin/bash
+# @sacloud-once
+# @sacloud-desc スタートアップスクリプトの実行完了後にシャットダウンします
 sh -c 'sleep 10; shutdown -h now' &
 exit 0`
 
@@ -329,67 +635,73 @@ func (d *Driver) buildSakuraServerSpec(publicKey string) *server.Builder {
 		interfaceDriver = types.InterfaceDrivers.E1000
 	}
 
-	var ost ostype.ArchiveOSType
-	switch d.serverConfig.OSType {
-	case "rancheros":
-		ost = ostype.RancherOS
-	case "centos":
-		ost = ostype.CentOS
-	case "ubuntu":
-		ost = ostype.Ubuntu
-	case "coreos":
-		ost = ostype.CoreOS
-	}
-
-	var diskPlan types.ID
-	switch d.serverConfig.DiskPlan {
-	case "ssd":
-		diskPlan = types.DiskPlans.SSD
-	case "hdd":
-		diskPlan = types.DiskPlans.HDD
-	}
-
-	var diskConn types.EDiskConnection
-	switch d.serverConfig.DiskConnection {
-	case "virtio":
-		diskConn = types.DiskConnections.VirtIO
-	case "ide":
-		diskConn = types.DiskConnections.IDE
-	}
-
 	var notes []string
-	if d.serverConfig.IsUbuntu() {
-		// add startup-script for allow sudo by ubuntu user
-		notes = append(notes, sakuraAllowSudoScriptBody)
-	} else if d.serverConfig.IsCentOS() {
-		notes = append(notes, fmt.Sprintf(sakuraInstallNetToolsScriptBody, d.EnginePort))
-	}
-
-	db := &diskBuilder.FromUnixBuilder{
-		OSType: ost,
-		Name:   d.serverConfig.HostName,
-		SizeGB: d.serverConfig.DiskSize,
-		//DistantFrom:   nil,
-		PlanID:     diskPlan,
-		Connection: diskConn,
-		// Description:   "",
-		// Tags:          nil,
-		// IconID:        0,
-		EditParameter: &diskBuilder.UnixEditRequest{
-			HostName:            d.serverConfig.HostName,
-			Password:            d.serverConfig.Password,
-			DisablePWAuth:       !d.serverConfig.EnablePWAuth,
-			EnableDHCP:          false,
-			ChangePartitionUUID: true,
-			// IPAddress:                 "",
-			// NetworkMaskLen:            0,
-			// DefaultRoute:              "",
-			SSHKeys:            []string{publicKey},
-			IsSSHKeysEphemeral: false,
-			IsNotesEphemeral:   true,
-			NoteContents:       notes,
-		},
-		Client: d.Client.DiskBuilderClient(),
+	for _, note := range d.startupNotes() {
+		notes = append(notes, note.Content)
+	}
+
+	var nic server.NICSettingHolder = &server.SharedNICSetting{
+		PacketFilterID: types.StringID(d.serverConfig.PacketFilter),
+	}
+	var ipAddress, defaultRoute string
+	var networkMaskLen int
+	if primary := d.serverConfig.primaryNIC(); primary != nil {
+		setting := primary.nicSetting()
+		setting.PacketFilterID = types.StringID(d.serverConfig.PacketFilter)
+		nic = setting
+
+		ipAddress = primary.IPAddress
+		networkMaskLen = primary.NetworkMaskLen
+		defaultRoute = d.serverConfig.Gateway
+	}
+
+	var additionalNICs []server.AdditionalNICSettingHolder
+	for _, n := range d.serverConfig.additionalNICs() {
+		additionalNICs = append(additionalNICs, n.nicSetting())
+	}
+
+	// 登録済みの場合はSSHキーリソースとして指定する
+	// (dry-run時は未登録のため、登録予定の公開鍵はdryRunPlan.SSHKeyとして表示する)
+	var sshKeys []string
+	var sshKeyIDs []types.ID
+	if d.SSHKeyResourceID != "" {
+		sshKeyIDs = append(sshKeyIDs, types.StringID(d.SSHKeyResourceID))
+	} else if !d.serverConfig.RegisterSSHKey {
+		sshKeys = append(sshKeys, publicKey)
+	}
+	for _, id := range d.serverConfig.SSHKeyIDs {
+		sshKeyIDs = append(sshKeyIDs, types.StringID(id))
+	}
+
+	editParameter := &diskBuilder.UnixEditRequest{
+		HostName:            d.serverConfig.HostName,
+		Password:            d.serverConfig.Password,
+		DisablePWAuth:       !d.serverConfig.EnablePWAuth,
+		EnableDHCP:          false,
+		ChangePartitionUUID: true,
+		IPAddress:           ipAddress,
+		NetworkMaskLen:      networkMaskLen,
+		DefaultRoute:        defaultRoute,
+		SSHKeys:             sshKeys,
+		SSHKeyIDs:           sshKeyIDs,
+		IsSSHKeysEphemeral:  false,
+		IsNotesEphemeral:    !d.serverConfig.KeepStartupScripts,
+		NoteContents:        notes,
+	}
+
+	// SourceArchiveIDはos-typeから検索したアーカイブ、もしくは--sakuracloud-source-archive-idの値
+	db := &diskBuilder.FromDiskOrArchiveBuilder{
+		SourceArchiveID: types.StringID(d.serverConfig.SourceArchiveID),
+		SourceDiskID:    types.StringID(d.serverConfig.SourceDiskID),
+		Name:            d.serverConfig.HostName,
+		SizeGB:          d.serverConfig.DiskSize,
+		PlanID:          diskPlanID(d.serverConfig.DiskPlan),
+		Connection:      diskConnection(d.serverConfig.DiskConnection),
+		Description:     d.serverConfig.Description,
+		Tags:            d.serverConfig.resourceTags(),
+		IconID:          types.StringID(d.serverConfig.IconID),
+		EditParameter:   editParameter,
+		Client:          d.Client.DiskBuilderClient(),
 	}
 
 	builder := &server.Builder{
@@ -397,21 +709,19 @@ func (d *Driver) buildSakuraServerSpec(publicKey string) *server.Builder {
 		CPU:             d.serverConfig.Core,
 		MemoryGB:        d.serverConfig.Memory,
 		GPU:             d.serverConfig.GPU,
-		Commitment:      types.Commitments.Standard, // TODO パラメータ化
-		Generation:      types.PlanGenerations.Default,
+		Commitment:      d.serverConfig.commitment(),
+		Generation:      d.serverConfig.planGeneration(),
 		InterfaceDriver: interfaceDriver,
-		//Description:     "",
-		//IconID:          0,
-		//Tags:            nil,
+		Description:     d.serverConfig.Description,
+		IconID:          types.StringID(d.serverConfig.IconID),
+		Tags:            d.serverConfig.resourceTags(),
 		BootAfterCreate: true,
 		//CDROMID:         0,
 		//PrivateHostID:   0,
-		NIC: &server.SharedNICSetting{
-			PacketFilterID: types.StringID(d.serverConfig.PacketFilter),
-		},
-		//AdditionalNICs: nil,
-		DiskBuilders: []diskBuilder.Builder{db},
-		Client:       d.Client.ServerBuilderClient(),
+		NIC:            nic,
+		AdditionalNICs: additionalNICs,
+		DiskBuilders:   append([]diskBuilder.Builder{db}, d.buildAdditionalDiskSpecs()...),
+		Client:         d.Client.ServerBuilderClient(),
 	}
 
 	log.Debugf("Build host spec %#v", builder)
@@ -431,64 +741,74 @@ func (d *Driver) createSSHKey() (string, error) {
 	return string(publicKey), nil
 }
 
+// registerSSHKey マシンの公開鍵をSSHキーリソースとして登録する
+func (d *Driver) registerSSHKey(ctx context.Context, publicKey string) error {
+	id, err := d.getClient().CreateSSHKey(
+		ctx,
+		d.serverConfig.HostName,
+		fmt.Sprintf("created by docker-machine for %s", d.GetMachineName()),
+		publicKey,
+	)
+	if err != nil {
+		return fmt.Errorf("error registering ssh key: %v", err)
+	}
+	d.SSHKeyResourceID = id
+	return nil
+}
+
 func (d *Driver) publicSSHKeyPath() string {
 	return d.GetSSHKeyPath() + ".pub"
 }
 
 // Kill force power off
 func (d *Driver) Kill() error {
-	return d.getClient().PowerOff(d.ID)
+	ctx := context.Background()
+	if err := d.getClient().PowerOff(ctx, d.ID, true); err != nil {
+		return err
+	}
+	return d.waitForServerByState(ctx, state.Stopped)
 }
 
-// Remove remove server
-func (d *Driver) Remove() error {
-	log.Infof("Removing sakura cloud server ...")
-
-	err := d.Kill()
-	if err != nil {
-		log.Errorf("Error stopping server: %v", err)
-	} else {
-		d.waitForServerByState(state.Stopped)
-	}
+// Restart restart server(call Reset, or PowerOff and PowerOn)
+func (d *Driver) Restart() error {
+	ctx := context.Background()
 
-	err = d.getClient().Delete(d.ID, []string{d.DiskID})
-	if err != nil {
-		log.Errorf("Error deleting server: %v", err)
-	} else {
-		log.Infof("Removed sakura cloud server.")
+	if d.RestartWithReset {
+		return d.getClient().Reset(ctx, d.ID)
 	}
 
-	return nil
-}
-
-// Restart restart server(call PowerOFf and PowerOn)
-func (d *Driver) Restart() error {
 	// PowerOff
-	if err := d.getClient().PowerOff(d.ID); err != nil {
+	if err := d.getClient().PowerOff(ctx, d.ID, false); err != nil {
 		return err
 	}
 
 	// wait
-	d.waitForServerByState(state.Stopped)
+	if err := d.waitForServerByState(ctx, state.Stopped); err != nil {
+		return err
+	}
 
 	//poweron
-	if err := d.getClient().PowerOn(d.ID); err != nil {
+	if err := d.getClient().PowerOn(ctx, d.ID); err != nil {
 		return err
 	}
 
 	//wait
-	d.waitForServerByState(state.Running)
-
-	//return
-	return nil
+	return d.waitForServerByState(ctx, state.Running)
 }
 
 // Start power on server
 func (d *Driver) Start() error {
-	return d.getClient().PowerOn(d.ID)
+	return d.getClient().PowerOn(context.Background(), d.ID)
 }
 
-// Stop power off server
+// Stop shutdown server gracefully(ACPI) and wait for it to stop
 func (d *Driver) Stop() error {
-	return d.getClient().PowerOff(d.ID)
+	ctx := context.Background()
+	if err := d.getClient().PowerOff(ctx, d.ID, false); err != nil {
+		return err
+	}
+	if err := d.waitForServerByState(ctx, state.Stopped); err != nil {
+		return fmt.Errorf("server did not stop gracefully, use 'docker-machine kill' to force power off: %v", err)
+	}
+	return nil
 }
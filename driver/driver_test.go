@@ -1,26 +1,261 @@
 package driver
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud/fake"
+	"github.com/sacloud/libsacloud/v2/sacloud/profile"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetConfigFromFlags(t *testing.T) {
+	fake.SwitchFactoryFuncToFake()
 	driver := NewDriver("default", "path")
 
 	checkFlags := &drivers.CheckDriverOptions{
 		FlagsValues: map[string]interface{}{
 			"sakuracloud-access-token":        "token",
 			"sakuracloud-access-token-secret": "secret",
-			"sakuracloud-region":              "region",
+			"sakuracloud-zone":                "is1a",
+			"sakuracloud-wait-timeout":        defaultWaitTimeout,
+			"sakuracloud-wait-interval":       defaultWaitInterval,
 		},
 		CreateFlags: driver.GetCreateFlags(),
 	}
 
-	driver.SetConfigFromFlags(checkFlags) // nolint
+	err := driver.SetConfigFromFlags(checkFlags)
 
-	//assert.NoError(t, err)
+	assert.NoError(t, err)
 	assert.Empty(t, checkFlags.InvalidFlags)
 }
+
+func TestSetConfigFromFlags_Profile(t *testing.T) {
+	fake.SwitchFactoryFuncToFake()
+	dir := t.TempDir()
+	t.Setenv(profile.DirectoryNameEnv, dir)
+	t.Setenv(profile.DirectoryNameEnvOld, dir)
+	require.NoError(t, profile.Save("with-zone", &profile.ConfigValue{AccessToken: "token", AccessTokenSecret: "secret", Zone: "is1a"}))
+	require.NoError(t, profile.Save("without-zone", &profile.ConfigValue{AccessToken: "token", AccessTokenSecret: "secret"}))
+
+	cases := []struct {
+		name    string
+		profile string
+		zone    string
+		expect  string
+	}{
+		{name: "flag over profile", profile: "with-zone", zone: "is1b", expect: "is1b"},
+		{name: "profile over empty", profile: "with-zone", expect: "is1a"},
+		{name: "zone fallback", profile: "without-zone", expect: defaultRegion},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			driver := NewDriver("default", "path").(*Driver)
+			checkFlags := &drivers.CheckDriverOptions{
+				FlagsValues: map[string]interface{}{
+					"sakuracloud-profile":       tc.profile,
+					"sakuracloud-zone":          tc.zone,
+					"sakuracloud-wait-timeout":  defaultWaitTimeout,
+					"sakuracloud-wait-interval": defaultWaitInterval,
+				},
+				CreateFlags: driver.GetCreateFlags(),
+			}
+			require.NoError(t, driver.SetConfigFromFlags(checkFlags))
+			assert.Equal(t, tc.expect, driver.Client.Zone)
+			// 認証情報はプロファイルから読み込み、config storeには保存しない
+			assert.Empty(t, driver.Client.AccessToken)
+			assert.Equal(t, tc.profile, driver.Client.Profile)
+		})
+	}
+}
+
+func TestParsePrivateNICs(t *testing.T) {
+	nics, err := parsePrivateNICs(
+		[]string{"111111111111", "222222222222"},
+		[]string{"192.168.0.11", "192.168.1.11"},
+		[]string{"255.255.255.0"},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, nics, 2)
+	assert.Equal(t, "192.168.0.11/24", nics[0].cidr())
+	assert.Equal(t, "192.168.1.11/24", nics[1].cidr())
+
+	_, err = parsePrivateNICs([]string{"111111111111"}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = parsePrivateNICs([]string{"111111111111"}, []string{"192.168.0.11"}, []string{"33"})
+	assert.Error(t, err)
+}
+
+func TestSplitEndpoint(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		host     string
+		port     int
+		err      bool
+	}{
+		{endpoint: "203.0.113.11", host: "203.0.113.11", port: 22},
+		{endpoint: "203.0.113.11:10022", host: "203.0.113.11", port: 10022},
+		{endpoint: "example.com:10022", host: "example.com", port: 10022},
+		{endpoint: "[2001:db8::1]:10022", host: "2001:db8::1", port: 10022},
+		{endpoint: "203.0.113.11:ssh", err: true},
+		{endpoint: "203.0.113.11:0", err: true},
+		{endpoint: "203.0.113.11:65536", err: true},
+	}
+	for _, tc := range cases {
+		host, port, err := splitEndpoint(tc.endpoint, "--sakuracloud-forward-endpoint")
+		if tc.err {
+			assert.Error(t, err, tc.endpoint)
+			continue
+		}
+		require.NoError(t, err, tc.endpoint)
+		assert.Equal(t, tc.host, host)
+		assert.Equal(t, tc.port, port)
+	}
+}
+
+func TestCredentialReference(t *testing.T) {
+	t.Setenv("SAKURACLOUD_TEST_CREDENTIAL", "from-env")
+
+	cases := []struct {
+		name   string
+		value  string
+		expect string
+	}{
+		{name: "empty", value: "", expect: ""},
+		{name: "from env", value: "from-env", expect: "env:SAKURACLOUD_TEST_CREDENTIAL"},
+		{name: "literal", value: "literal", expect: "literal"},
+		{name: "reference", value: "file:/path/to/secret", expect: "file:/path/to/secret"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, credentialReference("--flag", "SAKURACLOUD_TEST_CREDENTIAL", tc.value))
+		})
+	}
+}
+
+func TestSetConfigFromFlags_CredentialsFromEnv(t *testing.T) {
+	fake.SwitchFactoryFuncToFake()
+	for k, v := range map[string]string{"SAKURACLOUD_ACCESS_TOKEN": "token", "SAKURACLOUD_ACCESS_TOKEN_SECRET": "secret"} {
+		t.Setenv(k, v)
+	}
+	driver := NewDriver("default", "path").(*Driver)
+
+	// docker-machineは環境変数の値をフラグの値としてドライバへ渡す
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"sakuracloud-access-token":        "token",
+			"sakuracloud-access-token-secret": "secret",
+			"sakuracloud-zone":                "is1a",
+			"sakuracloud-wait-timeout":        defaultWaitTimeout,
+			"sakuracloud-wait-interval":       defaultWaitInterval,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+	require.NoError(t, driver.SetConfigFromFlags(checkFlags))
+
+	assert.Equal(t, "env:SAKURACLOUD_ACCESS_TOKEN", driver.Client.AccessToken)
+	assert.Equal(t, "env:SAKURACLOUD_ACCESS_TOKEN_SECRET", driver.Client.AccessTokenSecret)
+}
+
+func TestDriver_PreparePassword(t *testing.T) {
+	storePath := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+	driver := NewDriver("default", storePath).(*Driver)
+	driver.serverConfig = &sakuraServerConfig{}
+
+	require.NoError(t, driver.preparePassword())
+	require.NotEmpty(t, driver.serverConfig.Password)
+
+	info, err := os.Stat(driver.passwordPath())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(driver.passwordPath())
+	require.NoError(t, err)
+	assert.Equal(t, driver.serverConfig.Password+"\n", string(data))
+
+	// 指定済みのパスワードは上書きしない
+	driver.serverConfig.Password = "specified"
+	require.NoError(t, driver.preparePassword())
+	assert.Equal(t, "specified", driver.serverConfig.Password)
+}
+
+func TestServerState(t *testing.T) {
+	cases := []struct {
+		status       types.EServerInstanceStatus
+		availability types.EAvailability
+		expect       state.State
+		err          bool
+	}{
+		{status: types.ServerInstanceStatuses.Up, availability: types.Availabilities.Available, expect: state.Running},
+		{status: types.ServerInstanceStatuses.Cleaning, availability: types.Availabilities.Available, expect: state.Stopping},
+		{status: types.ServerInstanceStatuses.Down, availability: types.Availabilities.Available, expect: state.Stopped},
+		{status: serverInstanceStatusMigrating, availability: types.Availabilities.Available, expect: state.Starting},
+		{status: types.ServerInstanceStatuses.Unknown, availability: types.Availabilities.Migrating, expect: state.Starting},
+		{status: types.ServerInstanceStatuses.Down, availability: types.Availabilities.Failed, expect: state.Error, err: true},
+		{status: "unknown-status", availability: types.Availabilities.Available, expect: state.Error, err: true},
+	}
+	for _, tc := range cases {
+		s, err := serverState("123456789012", tc.status, tc.availability)
+		assert.Equal(t, tc.expect, s, "status:%q availability:%q", tc.status, tc.availability)
+		if tc.err {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestParseStartupScripts(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "script.sh")
+	require.NoError(t, os.WriteFile(file, []byte("#!/bin/bash\necho {{.PrivateIPAddress}}"), 0600))
+
+	params := &startupScriptParams{MachineName: "default", EnginePort: 2376, PrivateIPAddress: "192.168.0.11"}
+	scripts, err := parseStartupScripts([]string{
+		"#!/bin/bash\necho {{.MachineName}}:{{.EnginePort}}",
+		file,
+		"123456789012",
+	}, params)
+	require.NoError(t, err)
+	require.Len(t, scripts, 3)
+
+	assert.Equal(t, "inline#1", scripts[0].Source)
+	assert.Equal(t, "#!/bin/bash\necho default:2376", scripts[0].Content)
+	assert.Equal(t, file, scripts[1].Source)
+	assert.Equal(t, "#!/bin/bash\necho 192.168.0.11", scripts[1].Content)
+	assert.Equal(t, types.ID(123456789012), scripts[2].NoteID)
+	assert.Empty(t, scripts[2].Content)
+
+	_, err = parseStartupScripts([]string{"#!/bin/bash\necho {{.Unknown}}"}, params)
+	assert.Error(t, err)
+
+	_, err = parseStartupScripts([]string{filepath.Join(t.TempDir(), "not-exists.sh")}, params)
+	assert.Error(t, err)
+}
+
+func TestDriver_StartupNotes(t *testing.T) {
+	d := NewDriver("default", t.TempDir()).(*Driver)
+	d.serverConfig = &sakuraServerConfig{
+		OSType:         "ubuntu",
+		StartupScripts: []*startupScriptConfig{{Source: "inline#1", Content: "#!/bin/sh\nexit 0"}},
+	}
+
+	// ubuntuではスクリプトが失敗してもログを参照できるよう最初にsudoを設定し、最後にシャットダウンする
+	var names []string
+	for _, note := range d.startupNotes() {
+		names = append(names, note.Name)
+	}
+	assert.Equal(t, []string{"sudo setting for ubuntu user", "inline#1", "shutdown after startup scripts"}, names)
+
+	d.serverConfig.OSType = "centos"
+	names = nil
+	for _, note := range d.startupNotes() {
+		names = append(names, note.Name)
+	}
+	assert.Equal(t, []string{"inline#1", "net-tools installation"}, names)
+}
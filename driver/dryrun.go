@@ -0,0 +1,216 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	diskBuilder "github.com/sacloud/libsacloud/v2/helper/builder/disk"
+	"github.com/sacloud/libsacloud/v2/helper/builder/server"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// errDryRun --sakuracloud-dry-run指定時にリソースを作成せずに終了するためのエラー
+var errDryRun = fmt.Errorf("dry-run: no resources are created")
+
+// dryRunPlan --sakuracloud-dry-run指定時に表示する作成予定のリソース
+type dryRunPlan struct {
+	Zone          string
+	Server        *server.Builder
+	PacketFilter  *dryRunPacketFilter `json:",omitempty"`
+	SSHKey        *dryRunSSHKey       `json:",omitempty"`
+	EstimatedCost *estimatedCost      `json:",omitempty"`
+}
+
+type dryRunPacketFilter struct {
+	Name        string
+	Expressions []*sacloud.PacketFilterExpression
+}
+
+// dryRunSSHKey --sakuracloud-register-ssh-key指定時に登録するSSHキーリソース
+//
+// 作成時は登録したSSHキーリソースのIDをディスクの修正パラメータ(SSHKeyIDs)に指定する
+type dryRunSSHKey struct {
+	Name      string
+	PublicKey string
+}
+
+// estimatedCost サービスクラス(料金表)から算出した月額料金の概算(円)
+type estimatedCost struct {
+	Monthly      map[string]int
+	TotalMonthly int
+	// Unknown 料金が見つからなかったリソース、TotalMonthlyには含まれない
+	Unknown []string `json:",omitempty"`
+}
+
+// dryRun 作成予定のリソースと料金の概算を表示する
+func (d *Driver) dryRun(ctx context.Context) error {
+	plan := d.buildDryRunPlan(ctx)
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	log.Infof("Resources to be created:\n%s", string(data))
+	return errDryRun
+}
+
+// buildDryRunPlan 作成予定のリソースと料金の概算を組み立てる
+func (d *Driver) buildDryRunPlan(ctx context.Context) *dryRunPlan {
+	publicKey := "<generated public key>"
+	if d.SSHKey != "" {
+		if key, err := os.ReadFile(d.SSHKey + ".pub"); err == nil {
+			publicKey = string(key)
+		}
+	}
+
+	plan := &dryRunPlan{
+		Zone:   d.Client.Zone,
+		Server: redactBuilder(d.buildSakuraServerSpec(publicKey)),
+	}
+	if d.serverConfig.ManagedPacketFilter != nil {
+		plan.PacketFilter = &dryRunPacketFilter{
+			Name:        d.serverConfig.HostName,
+			Expressions: d.serverConfig.ManagedPacketFilter.expressions(d.EnginePort),
+		}
+	}
+	if d.serverConfig.RegisterSSHKey {
+		plan.SSHKey = &dryRunSSHKey{
+			Name:      d.serverConfig.HostName,
+			PublicKey: publicKey,
+		}
+	}
+
+	cost, err := d.estimateCost(ctx, plan.Server)
+	if err != nil {
+		log.Warnf("Failed to estimate cost: %s", err)
+	}
+	plan.EstimatedCost = cost
+	return plan
+}
+
+// redactBuilder 表示用にAPIクライアントとパスワードを除いたコピーを返す
+func redactBuilder(builder *server.Builder) *server.Builder {
+	redacted := *builder
+	redacted.Client = nil
+	redacted.DiskBuilders = nil
+	for _, db := range builder.DiskBuilders {
+		switch b := db.(type) {
+		case *diskBuilder.FromDiskOrArchiveBuilder:
+			copied := *b
+			copied.Client = nil
+			if b.EditParameter != nil {
+				editParameter := *b.EditParameter
+				if editParameter.Password != "" {
+					editParameter.Password = "********"
+				}
+				copied.EditParameter = &editParameter
+			}
+			db = &copied
+		case *diskBuilder.BlankBuilder:
+			copied := *b
+			copied.Client = nil
+			db = &copied
+		}
+		redacted.DiskBuilders = append(redacted.DiskBuilders, db)
+	}
+	return &redacted
+}
+
+// estimateCost サーバプランとディスクに対応するサービスクラスから月額料金を算出する
+func (d *Driver) estimateCost(ctx context.Context, builder *server.Builder) (*estimatedCost, error) {
+	classes, err := d.getClient().FindServiceClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := &estimatedCost{Monthly: make(map[string]int)}
+	add := func(resource string, match func(path string) bool) {
+		for _, class := range classes {
+			if class.Price != nil && match(class.ServiceClassPath) {
+				cost.Monthly[resource] = class.Price.Monthly
+				cost.TotalMonthly += class.Price.Monthly
+				return
+			}
+		}
+		cost.Unknown = append(cost.Unknown, resource)
+	}
+
+	add(fmt.Sprintf("server %s", builder.Name), func(path string) bool {
+		return matchServerServiceClass(path, builder.CPU, builder.MemoryGB, builder.GPU, builder.Commitment)
+	})
+	for _, db := range builder.DiskBuilders {
+		var name string
+		var planID types.ID
+		var size int
+		switch b := db.(type) {
+		case *diskBuilder.FromDiskOrArchiveBuilder:
+			name, planID, size = b.Name, b.PlanID, b.SizeGB
+		case *diskBuilder.BlankBuilder:
+			name, planID, size = b.Name, b.PlanID, b.SizeGB
+		default:
+			continue
+		}
+		add(fmt.Sprintf("disk %s", name), func(path string) bool {
+			return matchDiskServiceClass(path, planID, size)
+		})
+	}
+	return cost, nil
+}
+
+// serverServiceClassPattern サーバプランのサービスクラスのパス
+//
+// 例: cloud/plan/fixed/2core-4gb, cloud/plan/dedicatedcpu/2core-4gb, cloud/plan/gpu/4core-56gb-1gpu
+var serverServiceClassPattern = regexp.MustCompile(`^cloud/plan/((?:[^/]+/)*)(\d+)core-(\d+)gb(?:-(\d+)gpu)?$`)
+
+// diskServiceClassPattern ディスクのサービスクラスのパス
+//
+// 例: cloud/disk/ssd/20g, cloud/disk/hdd/2t
+var diskServiceClassPattern = regexp.MustCompile(`^cloud/disk/(ssd|hdd)/(?:[^/]+/)*(\d+)(g|t)$`)
+
+// matchServerServiceClass サービスクラスのパスがサーバプランに対応するか
+func matchServerServiceClass(path string, core, memory, gpu int, commitment types.ECommitment) bool {
+	m := serverServiceClassPattern.FindStringSubmatch(path)
+	if m == nil || m[2] != strconv.Itoa(core) || m[3] != strconv.Itoa(memory) {
+		return false
+	}
+	dedicated, pathGPU := false, 0
+	for _, segment := range strings.Split(strings.TrimSuffix(m[1], "/"), "/") {
+		switch segment {
+		case "dedicatedcpu":
+			dedicated = true
+		case "gpu":
+			// GPU数を含まないパスは1GPUのプランとみなす
+			pathGPU = 1
+		}
+	}
+	if m[4] != "" {
+		pathGPU, _ = strconv.Atoi(m[4])
+	}
+	if (commitment == types.Commitments.DedicatedCPU) != dedicated {
+		return false
+	}
+	return gpu == pathGPU
+}
+
+// matchDiskServiceClass サービスクラスのパスがディスクプランとサイズに対応するか
+func matchDiskServiceClass(path string, planID types.ID, sizeGB int) bool {
+	m := diskServiceClassPattern.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+	plan := "ssd"
+	if planID == types.DiskPlans.HDD {
+		plan = "hdd"
+	}
+	size, _ := strconv.Atoi(m[2])
+	if m[3] == "t" {
+		size *= 1024
+	}
+	return m[1] == plan && size == sizeGB
+}
@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchServerServiceClass(t *testing.T) {
+	cases := []struct {
+		path       string
+		core       int
+		memory     int
+		gpu        int
+		commitment types.ECommitment
+		expect     bool
+	}{
+		{path: "cloud/plan/fixed/1core-1gb", core: 1, memory: 1, expect: true},
+		{path: "cloud/plan/fixed/2core-4gb", core: 2, memory: 4, expect: true},
+		{path: "cloud/plan/fixed/12core-48gb", core: 2, memory: 48},
+		{path: "cloud/plan/fixed/2core-48gb", core: 2, memory: 4},
+		{path: "cloud/plan/fixed/2core-4gb", core: 2, memory: 4, commitment: types.Commitments.DedicatedCPU},
+		{path: "cloud/plan/dedicatedcpu/2core-4gb", core: 2, memory: 4, commitment: types.Commitments.DedicatedCPU, expect: true},
+		{path: "cloud/plan/dedicatedcpu/2core-4gb", core: 2, memory: 4},
+		{path: "cloud/plan/gpu/4core-56gb-1gpu", core: 4, memory: 56, gpu: 1, expect: true},
+		{path: "cloud/plan/gpu/4core-56gb", core: 4, memory: 56, gpu: 1, expect: true},
+		{path: "cloud/plan/gpu/4core-56gb-1gpu", core: 4, memory: 56},
+		{path: "cloud/plan/fixed/4core-56gb", core: 4, memory: 56, gpu: 1},
+		{path: "cloud/plan/1", core: 1, memory: 1},
+		{path: "cloud/disk/ssd/20g", core: 1, memory: 1},
+		{path: "cloud/plan/fixed/1core-1gb/license", core: 1, memory: 1},
+	}
+	for _, tc := range cases {
+		actual := matchServerServiceClass(tc.path, tc.core, tc.memory, tc.gpu, tc.commitment)
+		assert.Equal(t, tc.expect, actual, "%s: core=%d memory=%d gpu=%d commitment=%s", tc.path, tc.core, tc.memory, tc.gpu, tc.commitment)
+	}
+}
+
+func TestMatchDiskServiceClass(t *testing.T) {
+	cases := []struct {
+		path   string
+		planID types.ID
+		sizeGB int
+		expect bool
+	}{
+		{path: "cloud/disk/ssd/20g", planID: types.DiskPlans.SSD, sizeGB: 20, expect: true},
+		{path: "cloud/disk/hdd/40g", planID: types.DiskPlans.HDD, sizeGB: 40, expect: true},
+		{path: "cloud/disk/ssd/2t", planID: types.DiskPlans.SSD, sizeGB: 2048, expect: true},
+		{path: "cloud/disk/ssd/120g", planID: types.DiskPlans.SSD, sizeGB: 20},
+		{path: "cloud/disk/ssd/20g", planID: types.DiskPlans.HDD, sizeGB: 20},
+		{path: "cloud/disk/hdd/20g", planID: types.DiskPlans.SSD, sizeGB: 20},
+		{path: "cloud/disk/ssd/20gb", planID: types.DiskPlans.SSD, sizeGB: 20},
+		{path: "cloud/plan/fixed/1core-1gb", planID: types.DiskPlans.SSD, sizeGB: 1},
+		{path: "cloud/archive/ssd/20g", planID: types.DiskPlans.SSD, sizeGB: 20},
+	}
+	for _, tc := range cases {
+		actual := matchDiskServiceClass(tc.path, tc.planID, tc.sizeGB)
+		assert.Equal(t, tc.expect, actual, "%s: plan=%s size=%d", tc.path, tc.planID, tc.sizeGB)
+	}
+}
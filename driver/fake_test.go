@@ -0,0 +1,721 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+	diskBuilder "github.com/sacloud/libsacloud/v2/helper/builder/disk"
+	"github.com/sacloud/libsacloud/v2/helper/power"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/fake"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeDriver libsacloudのfakeドライバ(インメモリ)を利用するDriverを作成する
+func newFakeDriver(t *testing.T, flags map[string]interface{}) *Driver {
+	fake.SwitchFactoryFuncToFake()
+	// fakeドライバの状態遷移はミリ秒単位で完了するため、libsacloud内部のポーリング間隔も短くする
+	sacloud.DefaultStatePollingInterval = 10 * time.Millisecond
+	power.InitialRequestRetrySpan = 10 * time.Millisecond
+	// 起動/シャットダウンのリトライは状態遷移の完了より後にする、遷移中にリトライすると状態遷移が重複し後から状態が変わる
+	power.BootRetrySpan = time.Second
+	power.ShutdownRetrySpan = time.Second
+
+	storePath := t.TempDir()
+	name := "fake-machine"
+	require.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", name), 0700))
+
+	d := NewDriver(name, storePath).(*Driver)
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: fakeDriverFlags(flags),
+		CreateFlags: d.GetCreateFlags(),
+	}
+	require.NoError(t, d.SetConfigFromFlags(checkFlags))
+	require.Empty(t, checkFlags.InvalidFlags)
+	return d
+}
+
+// fakeDriverFlags fakeドライバで利用するフラグの値を返す、flagsの値で上書きする
+func fakeDriverFlags(flags map[string]interface{}) map[string]interface{} {
+	values := map[string]interface{}{
+		"sakuracloud-access-token":        "token",
+		"sakuracloud-access-token-secret": "secret",
+		"sakuracloud-zone":                "is1a",
+		"sakuracloud-os-type":             "debian",
+		"sakuracloud-wait-timeout":        30,
+		"sakuracloud-wait-interval":       1,
+	}
+	for k, v := range flags {
+		values[k] = v
+	}
+	return values
+}
+
+// setFakeDriverFlags fakeDriverFlagsの値でDriverを設定する、SetConfigFromFlagsのエラーを返す
+func setFakeDriverFlags(t *testing.T, flags map[string]interface{}) error {
+	d := NewDriver("fake-machine", t.TempDir())
+	return d.SetConfigFromFlags(&drivers.CheckDriverOptions{
+		FlagsValues: fakeDriverFlags(flags),
+		CreateFlags: d.GetCreateFlags(),
+	})
+}
+
+// saveFakeMachine docker-machineと同様にDriverの値をconfig.jsonへ保存する
+func saveFakeMachine(t *testing.T, d *Driver) {
+	driverData, err := json.Marshal(d)
+	require.NoError(t, err)
+	data, err := json.Marshal(map[string]json.RawMessage{
+		"DriverName": json.RawMessage(`"sakuracloud"`),
+		"Driver":     driverData,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(d.ResolveStorePath("config.json"), data, 0600))
+}
+
+// loadFakeMachine config.jsonに保存されたDriverを読み込む
+func loadFakeMachine(t *testing.T, d *Driver) *Driver {
+	data, err := os.ReadFile(d.ResolveStorePath("config.json"))
+	require.NoError(t, err)
+	var config map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &config))
+
+	loaded := NewDriver(d.GetMachineName(), d.StorePath).(*Driver)
+	require.NoError(t, json.Unmarshal(config["Driver"], loaded))
+	return loaded
+}
+
+// createFakeServer 指定の状態のサーバをfakeのデータストアに作成し、dのサーバとする
+func createFakeServer(t *testing.T, d *Driver, status types.EServerInstanceStatus) *sacloud.Server {
+	ctx := context.Background()
+	serverOp := sacloud.NewServerOp(nil)
+	sv, err := serverOp.Create(ctx, d.Client.Zone, &sacloud.ServerCreateRequest{
+		Name:     d.serverConfig.HostName,
+		CPU:      1,
+		MemoryMB: 1024,
+	})
+	require.NoError(t, err)
+	sv.InstanceStatus = status
+	sv.Availability = types.Availabilities.Available
+	fake.DataStore.Put(fake.ResourceServer, d.Client.Zone, sv.ID, sv)
+	t.Cleanup(func() {
+		fake.DataStore.Delete(fake.ResourceServer, d.Client.Zone, sv.ID)
+	})
+
+	d.ID = sv.ID.String()
+	return sv
+}
+
+func findFakeServers(t *testing.T, d *Driver) []*sacloud.Server {
+	searched, err := sacloud.NewServerOp(nil).Find(context.Background(), d.Client.Zone, &sacloud.FindCondition{})
+	require.NoError(t, err)
+
+	var servers []*sacloud.Server
+	for _, sv := range searched.Servers {
+		if sv.Name != d.serverConfig.HostName {
+			continue
+		}
+		for _, tag := range sv.Tags {
+			if tag == machineTag {
+				servers = append(servers, sv)
+				break
+			}
+		}
+	}
+	return servers
+}
+
+func TestDriver_Lifecycle(t *testing.T) {
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-additional-disk": []string{"size=40,plan=hdd"},
+	})
+	assert.Equal(t, "root", d.SSHUser)
+
+	require.NoError(t, d.PreCreateCheck())
+	require.NoError(t, d.Create())
+	assert.NotEmpty(t, d.ID)
+	assert.NotEmpty(t, d.DiskID)
+	assert.Len(t, d.AdditionalDiskIDs, 1)
+	assert.FileExists(t, d.GetSSHKeyPath())
+	assert.FileExists(t, d.passwordPath())
+
+	s, err := d.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+
+	url, err := d.GetURL()
+	require.NoError(t, err)
+	assert.NotEmpty(t, url)
+
+	require.NoError(t, d.Restart())
+	s, err = d.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+
+	require.NoError(t, d.Stop())
+	s, err = d.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+
+	require.NoError(t, d.Remove())
+	assert.Empty(t, findFakeServers(t, d))
+	for _, id := range d.diskIDs() {
+		exists, err := d.getClient().IsExistsDisk(context.Background(), id)
+		require.NoError(t, err)
+		assert.False(t, exists, "disk[id:%s] should be removed", id)
+	}
+
+	// 削除済みのサーバに対する操作
+	s, err = d.GetState()
+	assert.Equal(t, state.Error, s)
+	assert.IsType(t, &serverNotFoundError{}, err)
+	assert.NoError(t, d.Remove())
+}
+
+// shutdownServerOp シャットダウンのオプションを記録するServerAPI、ignoreの場合はシャットダウンを受け付けても停止しない
+type shutdownServerOp struct {
+	sacloud.ServerAPI
+	ignore  bool
+	options []*sacloud.ShutdownOption
+}
+
+func (o *shutdownServerOp) Shutdown(ctx context.Context, zone string, id types.ID, shutdownOption *sacloud.ShutdownOption) error {
+	o.options = append(o.options, shutdownOption)
+	if o.ignore {
+		return nil
+	}
+	return o.ServerAPI.Shutdown(ctx, zone, id, shutdownOption)
+}
+
+func TestDriver_Kill(t *testing.T) {
+	d := newFakeDriver(t, nil)
+	createFakeServer(t, d, types.ServerInstanceStatuses.Up)
+	op := &shutdownServerOp{ServerAPI: fake.NewServerOp()}
+	replaceFakeOp(t, fake.ResourceServer, op, fake.NewServerOp())
+
+	// 強制停止し、停止するまで待つ
+	require.NoError(t, d.Kill())
+	require.Len(t, op.options, 1)
+	assert.True(t, op.options[0].Force)
+	s, err := d.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestDriver_Stop(t *testing.T) {
+	t.Run("graceful", func(t *testing.T) {
+		d := newFakeDriver(t, nil)
+		createFakeServer(t, d, types.ServerInstanceStatuses.Up)
+		op := &shutdownServerOp{ServerAPI: fake.NewServerOp()}
+		replaceFakeOp(t, fake.ResourceServer, op, fake.NewServerOp())
+
+		require.NoError(t, d.Stop())
+		require.Len(t, op.options, 1)
+		assert.False(t, op.options[0].Force)
+		s, err := d.GetState()
+		require.NoError(t, err)
+		assert.Equal(t, state.Stopped, s)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		d := newFakeDriver(t, map[string]interface{}{"sakuracloud-wait-timeout": 2})
+		createFakeServer(t, d, types.ServerInstanceStatuses.Up)
+		op := &shutdownServerOp{ServerAPI: fake.NewServerOp(), ignore: true}
+		replaceFakeOp(t, fake.ResourceServer, op, fake.NewServerOp())
+
+		// シャットダウンに応答しない場合は--sakuracloud-wait-timeoutまで待ってエラーとする
+		start := time.Now()
+		err := d.Stop()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "use 'docker-machine kill'")
+		assert.True(t, time.Since(start) >= 2*time.Second, "should wait until wait-timeout")
+		require.Len(t, op.options, 1)
+		assert.False(t, op.options[0].Force)
+	})
+}
+
+func TestDriver_RegisterSSHKey(t *testing.T) {
+	fake.SwitchFactoryFuncToFake()
+	keyPath := filepath.Join(t.TempDir(), "id_rsa")
+	require.NoError(t, ssh.GenerateSSHKey(keyPath))
+	publicKey, err := os.ReadFile(keyPath + ".pub")
+	require.NoError(t, err)
+	registered, err := sacloud.NewSSHKeyOp(nil).Create(context.Background(), &sacloud.SSHKeyCreateRequest{
+		Name:      "registered",
+		PublicKey: string(publicKey),
+	})
+	require.NoError(t, err)
+
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-ssh-key-id":       []string{registered.ID.String()},
+		"sakuracloud-register-ssh-key": true,
+	})
+	require.NoError(t, d.Create())
+	require.NotEmpty(t, d.SSHKeyResourceID)
+
+	client := d.getClient()
+	exists, err := client.IsExistsSSHKey(context.Background(), types.StringID(d.SSHKeyResourceID))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, d.Remove())
+	exists, err = client.IsExistsSSHKey(context.Background(), types.StringID(d.SSHKeyResourceID))
+	require.NoError(t, err)
+	assert.False(t, exists, "registered ssh key should be removed")
+
+	// --sakuracloud-ssh-key-idで指定したSSHキーは削除しない
+	exists, err = client.IsExistsSSHKey(context.Background(), registered.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestDriver_CreateFailure(t *testing.T) {
+	d := newFakeDriver(t, nil)
+
+	// 存在しないアーカイブを指定し、サーバ作成後のディスク作成で失敗させる
+	d.serverConfig.SourceArchiveID = "999999999999"
+
+	require.Error(t, d.Create())
+	assert.Empty(t, findFakeServers(t, d), "created resources should be rolled back")
+}
+
+// failingDiskOp ディスクを作成した後にエラーを返すDiskAPI
+type failingDiskOp struct {
+	sacloud.DiskAPI
+}
+
+func (o *failingDiskOp) CreateWithConfig(ctx context.Context, zone string, createParam *sacloud.DiskCreateRequest, editParam *sacloud.DiskEditRequest, bootAtAvailable bool, distantFrom []types.ID) (*sacloud.Disk, error) {
+	disk, err := o.DiskAPI.CreateWithConfig(ctx, zone, createParam, editParam, bootAtAvailable, distantFrom)
+	if err != nil {
+		return nil, err
+	}
+	return disk, errors.New("failed to create disk")
+}
+
+func TestDriver_KeepOnFailure(t *testing.T) {
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-keep-on-failure":      true,
+		"sakuracloud-create-packet-filter": true,
+	})
+	// docker-machineはCreateの前に設定を保存し、失敗した場合は保存しない
+	saveFakeMachine(t, d)
+
+	// サーバ作成後のディスク作成で失敗させる
+	replaceFakeOp(t, fake.ResourceDisk, &failingDiskOp{DiskAPI: fake.NewDiskOp()}, fake.NewDiskOp())
+	require.Error(t, d.Create())
+	servers := findFakeServers(t, d)
+	require.Len(t, servers, 1, "created resources should be kept")
+
+	// fakeのサーバは作成直後に状態を持たないため、起動前の実環境と同じく停止状態にする
+	servers[0].InstanceStatus = types.ServerInstanceStatuses.Down
+	fake.DataStore.Put(fake.ResourceServer, d.Client.Zone, servers[0].ID, servers[0])
+
+	// 残したリソースのIDが保存され、docker-machine rmで削除できる
+	loaded := loadFakeMachine(t, d)
+	assert.Equal(t, servers[0].ID.String(), loaded.ID)
+	assert.NotEmpty(t, loaded.PacketFilterID)
+
+	require.NoError(t, loaded.Remove())
+	assert.Empty(t, findFakeServers(t, d))
+	exists, err := d.getClient().IsExistsPacketFilter(context.Background(), types.StringID(loaded.PacketFilterID))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestDriver_Rollback(t *testing.T) {
+	d := newFakeDriver(t, nil)
+	ctx := context.Background()
+	zone := d.Client.Zone
+
+	sv, err := sacloud.NewServerOp(nil).Create(ctx, zone, &sacloud.ServerCreateRequest{
+		Name:     d.serverConfig.HostName,
+		CPU:      1,
+		MemoryMB: 1024,
+	})
+	require.NoError(t, err)
+	diskOp := sacloud.NewDiskOp(nil)
+	var diskIDs []types.ID
+	for i := 0; i < 2; i++ {
+		disk, err := diskOp.Create(ctx, zone, &sacloud.DiskCreateRequest{
+			Name:       d.serverConfig.HostName,
+			DiskPlanID: types.DiskPlans.SSD,
+			SizeMB:     20 * 1024,
+			Connection: types.DiskConnections.VirtIO,
+		}, nil)
+		require.NoError(t, err)
+		diskIDs = append(diskIDs, disk.ID)
+	}
+	// 1つ目のディスクはサーバと共に削除される
+	require.NoError(t, diskOp.ConnectToServer(ctx, zone, diskIDs[0], sv.ID))
+
+	require.NoError(t, d.rollback(ctx, &createdResources{ServerID: sv.ID, DiskIDs: diskIDs}))
+	exists, err := d.getClient().IsExistsServer(ctx, sv.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	for _, id := range diskIDs {
+		exists, err := d.getClient().IsExistsDisk(ctx, id)
+		require.NoError(t, err)
+		assert.False(t, exists, "disk[id:%s] should be removed", id)
+	}
+}
+
+// filteringServerPlanOp プランの条件で検索結果を絞り込むServerPlanAPI
+//
+// fakeのServerPlanOp.FindはID/Name/Tags以外の条件を無視するため、存在しないプランの検証に用いる。
+// fakeのプランのMemoryMBはGB単位の値が設定されているため、メモリは条件に含めない。
+type filteringServerPlanOp struct {
+	sacloud.ServerPlanAPI
+}
+
+func (o *filteringServerPlanOp) Find(ctx context.Context, zone string, conditions *sacloud.FindCondition) (*sacloud.ServerPlanFindResult, error) {
+	searched, err := o.ServerPlanAPI.Find(ctx, zone, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &sacloud.ServerPlanFindResult{}
+	for _, plan := range searched.ServerPlans {
+		values := map[string]interface{}{
+			"CPU":        plan.CPU,
+			"GPU":        plan.GPU,
+			"Commitment": plan.Commitment,
+			"Generation": plan.Generation,
+		}
+		matched := true
+		for key, expected := range conditions.Filter {
+			if v, ok := values[key.String()]; ok && fmt.Sprint(v) != fmt.Sprint(expected) {
+				matched = false
+			}
+		}
+		if matched {
+			result.ServerPlans = append(result.ServerPlans, plan)
+		}
+	}
+	result.Count = len(result.ServerPlans)
+	result.Total = result.Count
+	return result, nil
+}
+
+func TestDriver_InvalidParameters(t *testing.T) {
+	fake.SwitchFactoryFuncToFake()
+	replaceFakeOp(t, fake.ResourceServerPlan, &filteringServerPlanOp{ServerPlanAPI: fake.NewServerPlanOp()}, fake.NewServerPlanOp())
+
+	cases := map[string]struct {
+		flags map[string]interface{}
+		err   string
+	}{
+		"missing archive":      {flags: map[string]interface{}{"sakuracloud-os-type": "name:not-exists-archive"}, err: "not-exists-archive"},
+		"invalid disk size":    {flags: map[string]interface{}{"sakuracloud-disk-size": 30}, err: "--sakuracloud-disk-size"},
+		"missing server":       {flags: map[string]interface{}{"sakuracloud-server-id": "999999999999"}, err: "999999999999"},
+		"missing ssh key":      {flags: map[string]interface{}{"sakuracloud-ssh-key-id": []string{"999999999999"}}, err: "999999999999"},
+		"invalid timeout":      {flags: map[string]interface{}{"sakuracloud-startup-script-timeout": 0}, err: "--sakuracloud-startup-script-timeout"},
+		"invalid commitment":   {flags: map[string]interface{}{"sakuracloud-commitment": "shared"}, err: "--sakuracloud-commitment"},
+		"invalid generation":   {flags: map[string]interface{}{"sakuracloud-plan-generation": 300}, err: "--sakuracloud-plan-generation"},
+		"nonexistent plan":     {flags: map[string]interface{}{"sakuracloud-core": 3}, err: "invalid plan"},
+		"nonexistent gpu plan": {flags: map[string]interface{}{"sakuracloud-gpu": 3}, err: "invalid plan"},
+		"missing switch": {
+			flags: map[string]interface{}{
+				"sakuracloud-os-type":         "ubuntu",
+				"sakuracloud-switch-id":       []string{"999999999999"},
+				"sakuracloud-private-ip":      []string{"192.168.0.11"},
+				"sakuracloud-private-netmask": []string{"255.255.255.0"},
+			},
+			err: "999999999999",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := setFakeDriverFlags(t, tc.flags)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestDriver_AdditionalNICWithDynamicOSType(t *testing.T) {
+	fake.SwitchFactoryFuncToFake()
+	switchOp := sacloud.NewSwitchOp(nil)
+	sw, err := switchOp.Create(context.Background(), "is1a", &sacloud.SwitchCreateRequest{Name: "fake-switch"})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, switchOp.Delete(context.Background(), "is1a", sw.ID)) })
+
+	nicFlags := map[string]interface{}{
+		"sakuracloud-switch-id":       []string{sw.ID.String()},
+		"sakuracloud-private-ip":      []string{"192.168.0.11"},
+		"sakuracloud-private-netmask": []string{"255.255.255.0"},
+	}
+
+	// OSの種類はアーカイブのタグから決まる
+	t.Run("ubuntu", func(t *testing.T) {
+		flags := map[string]interface{}{"sakuracloud-os-type": "tag:ubuntu-20.04-latest"}
+		for k, v := range nicFlags {
+			flags[k] = v
+		}
+		d := newFakeDriver(t, flags)
+		assert.True(t, d.serverConfig.IsUbuntu())
+		assert.Len(t, d.serverConfig.additionalNICs(), 1)
+	})
+	t.Run("debian", func(t *testing.T) {
+		flags := map[string]interface{}{"sakuracloud-os-type": "tag:debian-10-latest"}
+		for k, v := range nicFlags {
+			flags[k] = v
+		}
+		err := setFakeDriverFlags(t, flags)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "additional NICs are only supported")
+	})
+}
+
+func TestDriver_StartupScriptTimeout(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		err    error
+		expect string
+	}{
+		{name: "with logs", output: "script failed\n", expect: "startup script logs:\nscript failed"},
+		{name: "without logs", err: errors.New("ssh error"), expect: "see " + startupScriptLogPath + " on the server"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// fakeドライバではスタートアップスクリプトが実行されずシャットダウンされないため、タイムアウトとなる
+			d := newFakeDriver(t, map[string]interface{}{
+				"sakuracloud-os-type":                "ubuntu",
+				"sakuracloud-startup-script":         []string{"#!/bin/sh\nexit 1"},
+				"sakuracloud-startup-script-timeout": 1,
+			})
+
+			// ログの取得はSSHを利用するため置き換える
+			var executed string
+			d.runSSHCommand = func(_ drivers.Driver, cmd string) (string, error) {
+				executed = cmd
+				return tc.output, tc.err
+			}
+
+			err := d.Create()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "did not shut down within 1s")
+			assert.Contains(t, err.Error(), "inline#1")
+			assert.Contains(t, err.Error(), "sudo setting for ubuntu user")
+			assert.Contains(t, err.Error(), tc.expect)
+			assert.Contains(t, executed, startupScriptLogPath)
+			assert.Empty(t, findFakeServers(t, d), "created resources should be rolled back")
+		})
+	}
+}
+
+func TestDriver_Adopt(t *testing.T) {
+	created := newFakeDriver(t, nil)
+	require.NoError(t, created.Create())
+	t.Cleanup(func() { assert.NoError(t, created.Remove()) })
+
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-server-id": created.ID,
+	})
+	require.NoError(t, d.Create())
+	assert.True(t, d.Adopted)
+	assert.Equal(t, created.ID, d.ID)
+	assert.Equal(t, created.IPAddress, d.IPAddress)
+
+	// 取り込んだサーバはRemoveで削除しない
+	require.NoError(t, d.Remove())
+	exists, err := d.getClient().IsExistsServer(context.Background(), types.StringID(created.ID))
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestDriver_AdoptInvalidParameters(t *testing.T) {
+	created := newFakeDriver(t, nil)
+	require.NoError(t, created.Create())
+	t.Cleanup(func() { assert.NoError(t, created.Remove()) })
+
+	cases := map[string]map[string]interface{}{
+		"create packet filter": {"sakuracloud-create-packet-filter": true},
+		"packet filter":        {"sakuracloud-packet-filter": "123456789012"},
+		"additional disk":      {"sakuracloud-additional-disk": []string{"size=40"}},
+		"tag":                  {"sakuracloud-tag": []string{"foo"}},
+		"description":          {"sakuracloud-description": "foo"},
+		"icon":                 {"sakuracloud-icon-id": "123456789012"},
+		"source archive":       {"sakuracloud-source-archive-id": "123456789012"},
+		"source disk":          {"sakuracloud-source-disk-id": "123456789012"},
+		"keep on failure":      {"sakuracloud-keep-on-failure": true},
+		"startup script":       {"sakuracloud-startup-script": []string{"#!/bin/sh\nexit 0"}},
+		"ssh key id":           {"sakuracloud-ssh-key-id": []string{"123456789012"}},
+		"register ssh key":     {"sakuracloud-register-ssh-key": true},
+		"dry run":              {"sakuracloud-dry-run": true},
+		"additional nic": {
+			"sakuracloud-switch-id":  []string{"123456789012"},
+			"sakuracloud-private-ip": []string{"192.168.0.11"},
+		},
+		"private-only without gateway": {
+			"sakuracloud-switch-id":        []string{"123456789012"},
+			"sakuracloud-private-ip":       []string{"192.168.0.11"},
+			"sakuracloud-private-only":     true,
+			"sakuracloud-forward-endpoint": "203.0.113.11:10022",
+		},
+		"private-only with invalid ip": {
+			"sakuracloud-switch-id":        []string{"123456789012"},
+			"sakuracloud-private-ip":       []string{"192.168.0.256"},
+			"sakuracloud-private-only":     true,
+			"sakuracloud-gateway":          "192.168.0.1",
+			"sakuracloud-forward-endpoint": "203.0.113.11:10022",
+		},
+	}
+	for name, flags := range cases {
+		t.Run(name, func(t *testing.T) {
+			flags["sakuracloud-server-id"] = created.ID
+			assert.Error(t, setFakeDriverFlags(t, flags))
+		})
+	}
+}
+
+func TestDriver_DryRun(t *testing.T) {
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-dry-run": true,
+	})
+
+	putFakeServiceClasses(t, d.Client.Zone, map[string]int{
+		"cloud/plan/fixed/1core-1gb":        1815,
+		"cloud/plan/dedicatedcpu/1core-1gb": 4400,
+		"cloud/disk/ssd/20g":                1100,
+		"cloud/disk/hdd/20g":                440,
+	})
+
+	assert.Equal(t, errDryRun, d.PreCreateCheck())
+	assert.Empty(t, findFakeServers(t, d))
+	assert.False(t, types.StringID(d.serverConfig.SourceArchiveID).IsEmpty())
+
+	plan := d.buildDryRunPlan(context.Background())
+	require.NotNil(t, plan.EstimatedCost)
+	assert.Equal(t, &estimatedCost{
+		Monthly: map[string]int{
+			"server " + d.serverConfig.HostName: 1815,
+			"disk " + d.serverConfig.HostName:   1100,
+		},
+		TotalMonthly: 2915,
+	}, plan.EstimatedCost)
+}
+
+// putFakeServiceClasses パスと月額料金からサービスクラスをfakeドライバに登録する
+func putFakeServiceClasses(t *testing.T, zone string, prices map[string]int) {
+	id := types.ID(990000)
+	for path, monthly := range prices {
+		id++
+		class := &sacloud.ServiceClass{
+			ID:               id,
+			ServiceClassName: path,
+			ServiceClassPath: path,
+			IsPublic:         true,
+			Price:            &sacloud.Price{Zone: zone, Monthly: monthly},
+		}
+		fake.DataStore.Put(fake.ResourceServiceClass, zone, class.ID, class)
+		t.Cleanup(func() {
+			fake.DataStore.Delete(fake.ResourceServiceClass, zone, class.ID)
+		})
+	}
+}
+
+func TestDriver_DryRunRegisterSSHKey(t *testing.T) {
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-dry-run":          true,
+		"sakuracloud-register-ssh-key": true,
+	})
+	require.Equal(t, errDryRun, d.PreCreateCheck())
+
+	plan := d.buildDryRunPlan(context.Background())
+	require.NotNil(t, plan.SSHKey)
+	assert.Equal(t, d.serverConfig.HostName, plan.SSHKey.Name)
+
+	// 公開鍵はディスクの修正パラメータに直接指定せず、登録したSSHキーリソースとして指定する
+	require.Len(t, plan.Server.DiskBuilders, 1)
+	db, ok := plan.Server.DiskBuilders[0].(*diskBuilder.FromDiskOrArchiveBuilder)
+	require.True(t, ok)
+	assert.Empty(t, db.EditParameter.SSHKeys)
+}
+
+func TestDriver_ArchiveOnRemove(t *testing.T) {
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-archive-on-remove": true,
+		"sakuracloud-archive-name":      "{{.MachineName}}-{{.DiskID}}",
+	})
+	require.NoError(t, d.Create())
+
+	archiveName := d.GetMachineName() + "-" + d.DiskID
+	require.NoError(t, d.Remove())
+	assert.Empty(t, findFakeServers(t, d))
+
+	searched, err := sacloud.NewArchiveOp(nil).Find(context.Background(), d.Client.Zone, &sacloud.FindCondition{})
+	require.NoError(t, err)
+	var found bool
+	for _, archive := range searched.Archives {
+		if archive.Name == archiveName {
+			found = true
+			break
+		}
+	}
+	assert.True(t, found, "archive %q should be created", archiveName)
+}
+
+func TestDriver_ArchiveOnRemoveFailure(t *testing.T) {
+	d := newFakeDriver(t, map[string]interface{}{
+		"sakuracloud-archive-on-remove": true,
+	})
+	require.NoError(t, d.Create())
+
+	// アーカイブの作成に失敗させる
+	diskID := d.DiskID
+	d.DiskID = "999999999999"
+	require.Error(t, d.Remove())
+	assert.Len(t, findFakeServers(t, d), 1, "server should be kept when archiving fails")
+
+	d.DiskID = diskID
+	d.ArchiveOnRemove = false
+	require.NoError(t, d.Remove())
+}
+
+// replaceFakeOp テストの間だけリソースのfake APIをopに置き換え、終了後にoriginalに戻す
+func replaceFakeOp(t *testing.T, resource string, op, original interface{}) {
+	sacloud.SetClientFactoryFunc(resource, func(caller sacloud.APICaller) interface{} {
+		return op
+	})
+	t.Cleanup(func() {
+		sacloud.SetClientFactoryFunc(resource, func(caller sacloud.APICaller) interface{} {
+			return original
+		})
+	})
+}
+
+// emptyDiskPlanOp 利用可能なサイズを持たないディスクプランを返すDiskPlanAPI
+type emptyDiskPlanOp struct {
+	sacloud.DiskPlanAPI
+}
+
+func (o *emptyDiskPlanOp) Read(ctx context.Context, zone string, id types.ID) (*sacloud.DiskPlan, error) {
+	return &sacloud.DiskPlan{ID: id}, nil
+}
+
+func TestSakuraServerConfig_ValidateDiskSizeWithoutAvailableSizes(t *testing.T) {
+	d := newFakeDriver(t, nil)
+	replaceFakeOp(t, fake.ResourceDiskPlan, &emptyDiskPlanOp{DiskPlanAPI: fake.NewDiskPlanOp()}, fake.NewDiskPlanOp())
+
+	// APIがエラーなしでサイズを返さない場合は組み込みのサイズで検証する
+	err := d.serverConfig.validateDiskSize(context.Background(), d.getClient(), "ssd", 20, "--sakuracloud-disk-size")
+	require.NoError(t, err)
+	history := log.History()
+	require.NotEmpty(t, history)
+	assert.Contains(t, history[len(history)-1], "has no available sizes")
+	assert.NotContains(t, history[len(history)-1], "<nil>")
+
+	err = d.serverConfig.validateDiskSize(context.Background(), d.getClient(), "ssd", 21, "--sakuracloud-disk-size")
+	assert.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sacloud/libsacloud/v2/helper/builder/server"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+const (
+	defaultPrivateNetmask = 24 // 追加NICのデフォルトのネットマスク長
+	maxAdditionalNICs     = 9  // サーバに追加可能なNICの最大数
+)
+
+// privateNICConfig スイッチに接続する追加NICの設定
+type privateNICConfig struct {
+	SwitchID       string
+	IPAddress      string
+	NetworkMaskLen int
+}
+
+func parsePrivateNICs(switchIDs, ipAddresses, netmasks []string) ([]*privateNICConfig, error) {
+	if len(switchIDs) == 0 {
+		if len(ipAddresses) > 0 || len(netmasks) > 0 {
+			return nil, fmt.Errorf("%q is required when %q or %q is specified",
+				"--sakuracloud-switch-id", "--sakuracloud-private-ip", "--sakuracloud-private-netmask")
+		}
+		return nil, nil
+	}
+
+	if len(ipAddresses) != len(switchIDs) {
+		return nil, fmt.Errorf("%q must be specified the same number of times as %q",
+			"--sakuracloud-private-ip", "--sakuracloud-switch-id")
+	}
+	if len(netmasks) > 1 && len(netmasks) != len(switchIDs) {
+		return nil, fmt.Errorf("%q must be specified once or the same number of times as %q",
+			"--sakuracloud-private-netmask", "--sakuracloud-switch-id")
+	}
+
+	var nics []*privateNICConfig
+	for i := range switchIDs {
+		maskLen := defaultPrivateNetmask
+		if len(netmasks) > 0 {
+			netmask := netmasks[0]
+			if len(netmasks) > 1 {
+				netmask = netmasks[i]
+			}
+			l, err := parseNetmask(netmask)
+			if err != nil {
+				return nil, err
+			}
+			maskLen = l
+		}
+
+		nics = append(nics, &privateNICConfig{
+			SwitchID:       switchIDs[i],
+			IPAddress:      ipAddresses[i],
+			NetworkMaskLen: maskLen,
+		})
+	}
+	return nics, nil
+}
+
+// parseNetmask "24"のようなマスク長、または"255.255.255.0"のような表記を受け取りマスク長を返す
+func parseNetmask(v string) (int, error) {
+	if l, err := strconv.Atoi(v); err == nil {
+		if l < 1 || 32 < l {
+			return 0, fmt.Errorf("%q is invalid: %s", "--sakuracloud-private-netmask", v)
+		}
+		return l, nil
+	}
+
+	ip := net.ParseIP(v).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("%q is invalid: %s", "--sakuracloud-private-netmask", v)
+	}
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 || ones == 0 {
+		return 0, fmt.Errorf("%q is invalid: %s", "--sakuracloud-private-netmask", v)
+	}
+	return ones, nil
+}
+
+func (n *privateNICConfig) Validate() error {
+	if types.StringID(n.SwitchID).IsEmpty() {
+		return fmt.Errorf("%q is invalid: %s", "--sakuracloud-switch-id", n.SwitchID)
+	}
+	if ip := net.ParseIP(n.IPAddress); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("%q is invalid: %s", "--sakuracloud-private-ip", n.IPAddress)
+	}
+	return nil
+}
+
+func (n *privateNICConfig) nicSetting() *server.ConnectedNICSetting {
+	return &server.ConnectedNICSetting{
+		SwitchID:         types.StringID(n.SwitchID),
+		DisplayIPAddress: n.IPAddress,
+	}
+}
+
+func (n *privateNICConfig) cidr() string {
+	return fmt.Sprintf("%s/%d", n.IPAddress, n.NetworkMaskLen)
+}
+
+const sakuraPrivateNICNetplanScriptBody = `#!/bin/bash
+# @sacloud-once
+# @sacloud-desc docker-machine用に追加NICのIPアドレスを設定します
+# @sacloud-desc （このスクリプトは、Ubuntuでのみ動作します）
+# @sacloud-require-archive distro-ubuntu
+cat <<'EOF' > /etc/netplan/90-docker-machine-private.yaml || exit 1
+network:
+  version: 2
+  ethernets:
+%sEOF
+exit 0`
+
+const sakuraPrivateNICIfcfgScriptBody = `#!/bin/bash
+# @sacloud-once
+# @sacloud-desc docker-machine用に追加NICのIPアドレスを設定します
+# @sacloud-desc （このスクリプトは、CentOSでのみ動作します）
+# @sacloud-require-archive distro-centos
+%sexit 0`
+
+// primaryNIC eth0として利用する設定を返す、共有セグメントに接続する場合はnil
+func (c *sakuraServerConfig) primaryNIC() *privateNICConfig {
+	if c.PrivateOnly && len(c.PrivateNICs) > 0 {
+		return c.PrivateNICs[0]
+	}
+	return nil
+}
+
+// additionalNICs eth1以降として利用する設定を返す
+func (c *sakuraServerConfig) additionalNICs() []*privateNICConfig {
+	if c.PrivateOnly && len(c.PrivateNICs) > 0 {
+		return c.PrivateNICs[1:]
+	}
+	return c.PrivateNICs
+}
+
+// privateNICScript 追加NICのIPアドレスを設定するスタートアップスクリプトを返す
+//
+// 追加NICはeth1から順に割り当てられる。設定はスタートアップスクリプト実行後の再起動で反映される。
+func (c *sakuraServerConfig) privateNICScript() string {
+	nics := c.additionalNICs()
+	if len(nics) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	switch {
+	case c.IsUbuntu():
+		for i, nic := range nics {
+			fmt.Fprintf(&sb, "    eth%d:\n      dhcp4: false\n      addresses: [%s]\n", i+1, nic.cidr())
+		}
+		return fmt.Sprintf(sakuraPrivateNICNetplanScriptBody, sb.String())
+	case c.IsRHEL():
+		for i, nic := range nics {
+			fmt.Fprintf(&sb, "cat <<'EOF' > /etc/sysconfig/network-scripts/ifcfg-eth%d || exit 1\n", i+1)
+			fmt.Fprintf(&sb, "DEVICE=eth%d\nTYPE=Ethernet\nBOOTPROTO=static\nONBOOT=yes\nIPADDR=%s\nPREFIX=%d\nEOF\n",
+				i+1, nic.IPAddress, nic.NetworkMaskLen)
+		}
+		return fmt.Sprintf(sakuraPrivateNICIfcfgScriptBody, sb.String())
+	}
+	return ""
+}
+
+// resolveEndpoint プライベートネットワークのみに接続されたサーバへのSSH/Docker Engineの接続先を解決する
+//
+// VPCルータのポートフォワードもしくは--sakuracloud-forward-endpointで指定したポートフォワード先を経由して接続する。
+// SSHのプロキシ(踏み台ホスト)ではないため、Docker Engineへも接続先ホストの--sakuracloud-engine-portで接続する。
+// --sakuracloud-bastion-hostを指定した場合はローカルのトンネルから踏み台ホスト経由で接続する。
+func (d *Driver) resolveEndpoint(ctx context.Context) error {
+	if d.BastionHost != "" {
+		if err := d.validateBastion(); err != nil {
+			return err
+		}
+		d.IPAddress = bastionLocalAddress
+		return nil
+	}
+
+	if d.ForwardEndpoint != "" {
+		host, port, err := splitEndpoint(d.ForwardEndpoint, "--sakuracloud-forward-endpoint")
+		if err != nil {
+			return err
+		}
+		d.IPAddress = host
+		d.SSHPort = port
+		return nil
+	}
+
+	client := d.getClient()
+	host, sshPort, err := client.FindVPCRouterPortForwarding(ctx, d.VPCRouterID, d.PrivateIPAddress, 22)
+	if err != nil {
+		return err
+	}
+	_, enginePort, err := client.FindVPCRouterPortForwarding(ctx, d.VPCRouterID, d.PrivateIPAddress, d.EnginePort)
+	if err != nil {
+		return err
+	}
+	if enginePort != d.EnginePort {
+		return fmt.Errorf("port forwarding for docker engine must use the same global port as %q: %d", "--sakuracloud-engine-port", d.EnginePort)
+	}
+
+	d.IPAddress = host
+	d.SSHPort = sshPort
+	return nil
+}
+
+// splitEndpoint host[:port]形式の接続先を分割する、ポートを省略した場合は22番ポートとする
+func splitEndpoint(endpoint, flagName string) (string, int, error) {
+	if !strings.Contains(endpoint, ":") {
+		return endpoint, 22, nil
+	}
+	host, strPort, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", 0, fmt.Errorf("%q is invalid: %s", flagName, endpoint)
+	}
+	port, err := strconv.Atoi(strPort)
+	if err != nil || port < 1 || 65535 < port {
+		return "", 0, fmt.Errorf("%q is invalid: %s", flagName, endpoint)
+	}
+	return host, port, nil
+}
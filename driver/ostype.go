@@ -0,0 +1,215 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+
+	"github.com/sacloud/docker-machine-sakuracloud/sakuracloud"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/ostype"
+	"github.com/sacloud/libsacloud/v2/sacloud/search"
+	"github.com/sacloud/libsacloud/v2/sacloud/search/keys"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+const (
+	osTypeNamePrefix = "name:" // アーカイブ名(部分一致)で検索する場合のos-typeのプレフィックス
+	osTypeTagPrefix  = "tag:"  // タグ(カンマ区切りでAND)で検索する場合のos-typeのプレフィックス
+)
+
+// osFamily SSHユーザーやスタートアップスクリプトの準備方法を決めるOSの系統
+type osFamily int
+
+const (
+	osFamilyContainer osFamily = iota // RancherOS/CoreOSなど、Dockerがプリインストールされたもの
+	osFamilyUbuntu
+	osFamilyDebian
+	osFamilyRHEL  // CentOS
+	osFamilyOther // 系統が判別できないもの(スタートアップスクリプトによる準備は行わない)
+)
+
+// osTypeInfo --sakuracloud-os-typeごとのアーカイブとOSの情報
+type osTypeInfo struct {
+	// ArchiveFilter アーカイブを検索するための条件
+	ArchiveFilter search.Filter
+	SSHUser       string
+	Family        osFamily
+}
+
+func tagsFilter(tags ...string) search.Filter {
+	return search.Filter{
+		search.Key(keys.Tags): search.TagsAndEqual(tags...),
+	}
+}
+
+var osTypes = map[string]*osTypeInfo{
+	"ubuntu":     {ArchiveFilter: ostype.ArchiveCriteria[ostype.Ubuntu], SSHUser: "ubuntu", Family: osFamilyUbuntu},
+	"ubuntu2004": {ArchiveFilter: ostype.ArchiveCriteria[ostype.Ubuntu2004], SSHUser: "ubuntu", Family: osFamilyUbuntu},
+	"ubuntu2204": {ArchiveFilter: tagsFilter("ubuntu-22.04-latest"), SSHUser: "ubuntu", Family: osFamilyUbuntu},
+	"debian":     {ArchiveFilter: ostype.ArchiveCriteria[ostype.Debian], SSHUser: "root", Family: osFamilyDebian},
+
+	// deprecated: EOLを迎えているが互換性のために残している
+	"centos":    {ArchiveFilter: ostype.ArchiveCriteria[ostype.CentOS], SSHUser: "root", Family: osFamilyRHEL},
+	"rancheros": {ArchiveFilter: ostype.ArchiveCriteria[ostype.RancherOS], SSHUser: "rancher", Family: osFamilyContainer},
+	"coreos":    {ArchiveFilter: ostype.ArchiveCriteria[ostype.CoreOS], SSHUser: "core", Family: osFamilyContainer},
+}
+
+// unsupportedDistroTags docker-machine(libmachine)に対応するプロビジョナーが無いOSのアーカイブのタグ
+//
+// libmachineは/etc/os-releaseのIDが完全一致する場合のみプロビジョナーを選択するため、
+// Rocky Linux(rocky)/AlmaLinux(almalinux)/Miracle Linux(miraclelinux)/Flatcar(flatcar)はプロビジョニングできない
+var unsupportedDistroTags = []string{"distro-rocky", "distro-alma", "distro-miracle", "distro-flatcar"}
+
+// isDynamicOSType os-typeがアーカイブ名もしくはタグでの検索条件であるか
+func isDynamicOSType(v string) bool {
+	return strings.HasPrefix(v, osTypeNamePrefix) || strings.HasPrefix(v, osTypeTagPrefix)
+}
+
+// dynamicOSTypeFilter "name:xxx"もしくは"tag:xxx,yyy"形式のos-typeから検索条件を返す
+func dynamicOSTypeFilter(v string) (search.Filter, error) {
+	switch {
+	case strings.HasPrefix(v, osTypeNamePrefix):
+		name := strings.TrimSpace(strings.TrimPrefix(v, osTypeNamePrefix))
+		if name == "" {
+			break
+		}
+		return search.Filter{
+			search.Key(keys.Name): search.PartialMatch(name),
+		}, nil
+	case strings.HasPrefix(v, osTypeTagPrefix):
+		var tags []string
+		for _, tag := range strings.Split(strings.TrimPrefix(v, osTypeTagPrefix), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			break
+		}
+		return tagsFilter(tags...), nil
+	}
+	return nil, fmt.Errorf("%q is invalid: %s", "--sakuracloud-os-type", v)
+}
+
+// osTypeInfoFromTags アーカイブのタグからOSの情報を推測する
+func osTypeInfoFromTags(tags types.Tags) *osTypeInfo {
+	has := func(tag string) bool {
+		for _, t := range tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("distro-ubuntu"):
+		return &osTypeInfo{SSHUser: "ubuntu", Family: osFamilyUbuntu}
+	case has("distro-debian"):
+		return &osTypeInfo{SSHUser: "root", Family: osFamilyDebian}
+	case has("distro-centos"):
+		return &osTypeInfo{SSHUser: "root", Family: osFamilyRHEL}
+	case has("distro-coreos"):
+		return &osTypeInfo{SSHUser: "core", Family: osFamilyContainer}
+	case has("distro-rancheros"), has("distro-k3os"):
+		return &osTypeInfo{SSHUser: "rancher", Family: osFamilyContainer}
+	}
+	return &osTypeInfo{SSHUser: "root", Family: osFamilyOther}
+}
+
+// osTypeInfo --sakuracloud-os-typeに対応する情報を返す、アーカイブの検索前の場合はnil
+func (c *sakuraServerConfig) osTypeInfo() *osTypeInfo {
+	if info, ok := osTypes[c.OSType]; ok {
+		return info
+	}
+	return c.resolvedOSType
+}
+
+// resolveArchive --sakuracloud-os-typeに対応するアーカイブをゾーンごとに検索しSourceArchiveIDに設定する
+func resolveArchive(ctx context.Context, client *sakuracloud.APIClient, config *sakuraServerConfig) error {
+	if config.SourceArchiveID != "" || config.SourceDiskID != "" {
+		return nil
+	}
+
+	var filter search.Filter
+	if isDynamicOSType(config.OSType) {
+		f, err := dynamicOSTypeFilter(config.OSType)
+		if err != nil {
+			return err
+		}
+		filter = f
+	} else {
+		filter = osTypes[config.OSType].ArchiveFilter
+	}
+
+	archives, err := client.FindArchives(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if len(archives) == 0 {
+		return archiveNotFoundError(ctx, client, config.OSType)
+	}
+
+	archive := newestArchive(archives)
+	if len(archives) > 1 {
+		log.Infof("%d archives match %q, using the newest one: %s[id:%s]", len(archives), config.OSType, archive.Name, archive.ID)
+	}
+	config.SourceArchiveID = archive.ID.String()
+	if isDynamicOSType(config.OSType) {
+		if err := validateArchiveDistro(archive); err != nil {
+			return err
+		}
+		config.resolvedOSType = osTypeInfoFromTags(archive.Tags)
+	}
+	return nil
+}
+
+// newestArchive 作成日時が最も新しいアーカイブを返す、作成日時が同じ場合はIDの大きいものを返す
+//
+// APIの返す順序に依存せずに同じ検索条件から同じアーカイブを選択するため
+func newestArchive(archives []*sacloud.Archive) *sacloud.Archive {
+	sorted := make([]*sacloud.Archive, len(archives))
+	copy(sorted, archives)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+	return sorted[0]
+}
+
+// validateArchiveDistro docker-machineでプロビジョニングできないOSのアーカイブの場合はエラーを返す
+//
+// サーバ作成後のプロビジョニングで失敗するため、作成前に検出する
+func validateArchiveDistro(archive *sacloud.Archive) error {
+	for _, tag := range archive.Tags {
+		for _, unsupported := range unsupportedDistroTags {
+			if tag == unsupported {
+				return fmt.Errorf("archive %q[id:%s] is not supported: docker-machine has no provisioner for %q",
+					archive.Name, archive.ID, strings.TrimPrefix(tag, "distro-"))
+			}
+		}
+	}
+	return nil
+}
+
+// archiveNotFoundError 検索候補としてゾーン内のパブリックアーカイブの一覧を含めたエラーを返す
+func archiveNotFoundError(ctx context.Context, client *sakuracloud.APIClient, osType string) error {
+	candidates, err := client.FindArchives(ctx, search.Filter{
+		search.Key(keys.Scope): string(types.Scopes.Shared),
+	})
+	if err != nil || len(candidates) == 0 {
+		return fmt.Errorf("archive for %q is not found in zone %q", osType, client.Zone)
+	}
+
+	var lines []string
+	for _, archive := range candidates {
+		lines = append(lines, fmt.Sprintf("  - %s [tags: %s]", archive.Name, strings.Join(archive.Tags, ",")))
+	}
+	return fmt.Errorf("archive for %q is not found in zone %q, candidates are:\n%s",
+		osType, client.Zone, strings.Join(lines, "\n"))
+}
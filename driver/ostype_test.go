@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/search"
+	"github.com/sacloud/libsacloud/v2/sacloud/search/keys"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicOSTypeFilter(t *testing.T) {
+	cases := []struct {
+		osType string
+		expect search.Filter
+		err    bool
+	}{
+		{
+			osType: "name:Ubuntu Server 22.04",
+			expect: search.Filter{search.Key(keys.Name): search.PartialMatch("Ubuntu Server 22.04")},
+		},
+		{
+			osType: "tag:current-stable, distro-ubuntu",
+			expect: search.Filter{search.Key(keys.Tags): search.TagsAndEqual("current-stable", "distro-ubuntu")},
+		},
+		{osType: "name:", err: true},
+		{osType: "name:  ", err: true},
+		{osType: "tag:,", err: true},
+		{osType: "ubuntu", err: true},
+	}
+	for _, tc := range cases {
+		filter, err := dynamicOSTypeFilter(tc.osType)
+		if tc.err {
+			assert.Error(t, err, tc.osType)
+			continue
+		}
+		require.NoError(t, err, tc.osType)
+		assert.Equal(t, tc.expect, filter, tc.osType)
+	}
+}
+
+func TestOSTypeInfoFromTags(t *testing.T) {
+	cases := []struct {
+		tags   types.Tags
+		user   string
+		family osFamily
+	}{
+		{tags: types.Tags{"current-stable", "distro-ubuntu"}, user: "ubuntu", family: osFamilyUbuntu},
+		{tags: types.Tags{"distro-debian"}, user: "root", family: osFamilyDebian},
+		{tags: types.Tags{"distro-centos"}, user: "root", family: osFamilyRHEL},
+		{tags: types.Tags{"distro-coreos"}, user: "core", family: osFamilyContainer},
+		{tags: types.Tags{"distro-rancheros"}, user: "rancher", family: osFamilyContainer},
+		{tags: types.Tags{"distro-k3os"}, user: "rancher", family: osFamilyContainer},
+		{tags: types.Tags{"my-archive"}, user: "root", family: osFamilyOther},
+		{tags: nil, user: "root", family: osFamilyOther},
+	}
+	for _, tc := range cases {
+		info := osTypeInfoFromTags(tc.tags)
+		assert.Equal(t, tc.user, info.SSHUser, "%v", tc.tags)
+		assert.Equal(t, tc.family, info.Family, "%v", tc.tags)
+	}
+}
+
+func TestNewestArchive(t *testing.T) {
+	now := time.Now()
+	archives := []*sacloud.Archive{
+		{ID: 100000000001, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 100000000003, CreatedAt: now},
+		{ID: 100000000002, CreatedAt: now.Add(-time.Hour)},
+		{ID: 100000000004, CreatedAt: now},
+	}
+	assert.Equal(t, types.ID(100000000004), newestArchive(archives).ID)
+	// 元のスライスの順序は変更しない
+	assert.Equal(t, types.ID(100000000001), archives[0].ID)
+
+	// APIの返す順序に依存しない
+	reversed := []*sacloud.Archive{archives[3], archives[2], archives[1], archives[0]}
+	assert.Equal(t, types.ID(100000000004), newestArchive(reversed).ID)
+}
+
+func TestValidateArchiveDistro(t *testing.T) {
+	cases := map[string]bool{
+		"distro-ubuntu":  true,
+		"distro-debian":  true,
+		"distro-centos":  true,
+		"distro-rocky":   false,
+		"distro-alma":    false,
+		"distro-miracle": false,
+		"distro-flatcar": false,
+	}
+	for tag, valid := range cases {
+		archive := &sacloud.Archive{ID: 123456789012, Name: tag, Tags: types.Tags{"current-stable", tag}}
+		err := validateArchiveDistro(archive)
+		if valid {
+			assert.NoError(t, err, tag)
+		} else {
+			assert.Error(t, err, tag)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+const (
+	// maxPacketFilterExpressions パケットフィルタに登録可能なルールの最大数
+	maxPacketFilterExpressions = 30
+	// fixedPacketFilterExpressions 戻りパケットやICMPなど常に登録するルールの数
+	fixedPacketFilterExpressions = 6
+)
+
+// packetFilterConfig ドライバが作成するパケットフィルタの設定
+type packetFilterConfig struct {
+	AllowPorts []string
+	Sources    []string
+}
+
+func (p *packetFilterConfig) Validate() error {
+	for _, port := range p.AllowPorts {
+		if !isValidPortRange(port) {
+			return fmt.Errorf("%q is invalid: %s", "--sakuracloud-packet-filter-allow-port", port)
+		}
+	}
+	for _, source := range p.Sources {
+		if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+			return fmt.Errorf("%q is invalid: %s", "--sakuracloud-packet-filter-source", source)
+		}
+	}
+	if len(p.expressions(0)) > maxPacketFilterExpressions {
+		return fmt.Errorf("too many packet filter rules: number of %q * number of ports(SSH, Docker Engine and %q) must be %d or less",
+			"--sakuracloud-packet-filter-source", "--sakuracloud-packet-filter-allow-port",
+			maxPacketFilterExpressions-fixedPacketFilterExpressions)
+	}
+	return nil
+}
+
+// isValidPortRange "80"または"8000-8080"の形式のポート指定であるか
+//
+// 範囲指定の場合は開始ポートが終了ポート以下である必要がある
+func isValidPortRange(v string) bool {
+	var ports []int
+	for _, p := range strings.SplitN(v, "-", 2) {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || 65535 < n {
+			return false
+		}
+		ports = append(ports, n)
+	}
+	return len(ports) == 1 || ports[0] <= ports[1]
+}
+
+// expressions SSH/Docker Engine/追加ポートへの接続と戻りパケットのみを許可するルールを返す
+func (p *packetFilterConfig) expressions(enginePort int) []*sacloud.PacketFilterExpression {
+	ports := append([]string{"22", strconv.Itoa(enginePort)}, p.AllowPorts...)
+	sources := p.Sources
+	if len(sources) == 0 {
+		sources = []string{""} // any
+	}
+
+	var expressions []*sacloud.PacketFilterExpression
+	for _, source := range sources {
+		for _, port := range ports {
+			expressions = append(expressions, &sacloud.PacketFilterExpression{
+				Protocol:        types.Protocols.TCP,
+				SourceNetwork:   types.PacketFilterNetwork(source),
+				DestinationPort: types.PacketFilterPort(port),
+				Action:          types.Actions.Allow,
+			})
+		}
+	}
+
+	// for return traffic and ICMP
+	expressions = append(expressions,
+		&sacloud.PacketFilterExpression{
+			Protocol:        types.Protocols.TCP,
+			DestinationPort: "32768-61000",
+			Action:          types.Actions.Allow,
+			Description:     "return traffic",
+		},
+		&sacloud.PacketFilterExpression{
+			Protocol:        types.Protocols.UDP,
+			DestinationPort: "32768-61000",
+			Action:          types.Actions.Allow,
+			Description:     "return traffic",
+		},
+		&sacloud.PacketFilterExpression{
+			Protocol:    types.Protocols.UDP,
+			SourcePort:  "123",
+			Action:      types.Actions.Allow,
+			Description: "NTP",
+		},
+		&sacloud.PacketFilterExpression{
+			Protocol: types.Protocols.ICMP,
+			Action:   types.Actions.Allow,
+		},
+		&sacloud.PacketFilterExpression{
+			Protocol: types.Protocols.Fragment,
+			Action:   types.Actions.Allow,
+		},
+		&sacloud.PacketFilterExpression{
+			Protocol:    types.Protocols.IP,
+			Action:      types.Actions.Deny,
+			Description: "deny all",
+		},
+	)
+	return expressions
+}
+
+func (d *Driver) createPacketFilter(ctx context.Context) error {
+	expressions := d.serverConfig.ManagedPacketFilter.expressions(d.EnginePort)
+	id, err := d.getClient().CreatePacketFilter(
+		ctx,
+		d.serverConfig.HostName,
+		fmt.Sprintf("created by docker-machine for %s", d.GetMachineName()),
+		expressions,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating packet filter: %v", err)
+	}
+	d.PacketFilterID = id
+	d.serverConfig.PacketFilter = id
+	return nil
+}
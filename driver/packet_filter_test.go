@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidPortRange(t *testing.T) {
+	cases := map[string]bool{
+		"80":        true,
+		"1":         true,
+		"65535":     true,
+		"8000-8080": true,
+		"8080-8080": true,
+		"8080-80":   false,
+		"0":         false,
+		"65536":     false,
+		"80-":       false,
+		"-80":       false,
+		"http":      false,
+		"80-90-100": false,
+		"":          false,
+	}
+	for port, expect := range cases {
+		assert.Equal(t, expect, isValidPortRange(port), port)
+	}
+}
+
+func TestPacketFilterConfig_Validate(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *packetFilterConfig
+		err    string
+	}{
+		{name: "empty", config: &packetFilterConfig{}},
+		{name: "ports and sources", config: &packetFilterConfig{AllowPorts: []string{"80", "8000-8080"}, Sources: []string{"192.0.2.1", "198.51.100.0/24"}}},
+		{name: "reversed port range", config: &packetFilterConfig{AllowPorts: []string{"8080-80"}}, err: "is invalid: 8080-80"},
+		{name: "invalid source", config: &packetFilterConfig{Sources: []string{"198.51.100.0/33"}}, err: "is invalid: 198.51.100.0/33"},
+		{
+			// (2 + 4ports) * 4sources + 6 = 30 rules
+			name:   "maximum rules",
+			config: &packetFilterConfig{AllowPorts: []string{"80", "443", "8080", "8443"}, Sources: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"}},
+		},
+		{
+			name:   "too many rules",
+			config: &packetFilterConfig{AllowPorts: []string{"80", "443", "8080", "8443", "9000"}, Sources: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"}},
+			// 固定の6ルールを除いた上限を示す
+			err: "must be 24 or less",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPacketFilterConfig_Expressions(t *testing.T) {
+	config := &packetFilterConfig{
+		AllowPorts: []string{"80", "8000-8080"},
+		Sources:    []string{"192.0.2.1", "198.51.100.0/24"},
+	}
+	expressions := config.expressions(2376)
+	require.Len(t, expressions, 2*4+fixedPacketFilterExpressions)
+
+	// 許可するポートは送信元ごとにSSH/Docker Engine/追加ポートの順
+	var allowed []string
+	for _, exp := range expressions[:8] {
+		assert.Equal(t, types.Protocols.TCP, exp.Protocol)
+		assert.Equal(t, types.Actions.Allow, exp.Action)
+		allowed = append(allowed, fmt.Sprintf("%s:%s", exp.SourceNetwork, exp.DestinationPort))
+	}
+	assert.Equal(t, []string{
+		"192.0.2.1:22", "192.0.2.1:2376", "192.0.2.1:80", "192.0.2.1:8000-8080",
+		"198.51.100.0/24:22", "198.51.100.0/24:2376", "198.51.100.0/24:80", "198.51.100.0/24:8000-8080",
+	}, allowed)
+
+	// 戻りパケットは送信元を問わず許可する
+	for _, exp := range expressions[8:10] {
+		assert.Equal(t, types.Actions.Allow, exp.Action)
+		assert.Empty(t, exp.SourceNetwork)
+		assert.Equal(t, types.PacketFilterPort("32768-61000"), exp.DestinationPort)
+	}
+	assert.Equal(t, types.Protocols.TCP, expressions[8].Protocol)
+	assert.Equal(t, types.Protocols.UDP, expressions[9].Protocol)
+
+	// 最後のルールで全て拒否する
+	last := expressions[len(expressions)-1]
+	assert.Equal(t, types.Protocols.IP, last.Protocol)
+	assert.Equal(t, types.Actions.Deny, last.Action)
+	assert.Empty(t, last.SourceNetwork)
+	assert.Empty(t, last.DestinationPort)
+
+	// 送信元を指定しない場合は全ての送信元から許可する
+	expressions = (&packetFilterConfig{}).expressions(2376)
+	require.Len(t, expressions, 2+6)
+	assert.Empty(t, expressions[0].SourceNetwork)
+	assert.Equal(t, types.PacketFilterPort("22"), expressions[0].DestinationPort)
+	assert.Equal(t, types.PacketFilterPort("2376"), expressions[1].DestinationPort)
+}
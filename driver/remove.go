@@ -0,0 +1,184 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// removeLocalOnlyEnvVar trueが設定されている場合、Removeはさくらのクラウド上のリソースを削除せずローカルの状態のみを削除する
+const removeLocalOnlyEnvVar = "SAKURACLOUD_REMOVE_LOCAL_ONLY"
+
+// multiError 複数のエラーをまとめたもの
+type multiError []error
+
+func (e multiError) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e multiError) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func isRemoveLocalOnly() bool {
+	v, err := strconv.ParseBool(os.Getenv(removeLocalOnlyEnvVar))
+	return err == nil && v
+}
+
+// Remove remove server
+func (d *Driver) Remove() error {
+	if isRemoveLocalOnly() {
+		log.Warnf("%s is set, skipping removal of sakura cloud resources: server[id:%s]", removeLocalOnlyEnvVar, d.ID)
+		return nil
+	}
+
+	if d.Adopted {
+		log.Warnf("server[id:%s] was adopted with --sakuracloud-server-id, skipping removal of sakura cloud resources", d.ID)
+		return nil
+	}
+
+	log.Infof("Removing sakura cloud server ...")
+
+	ctx := context.Background()
+	var errs multiError
+
+	if err := d.removeServer(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		log.Infof("Removed sakura cloud server.")
+	}
+
+	// packet filter can be deleted only after the server is deleted
+	if d.PacketFilterID != "" && len(errs) == 0 {
+		err := d.getClient().DeletePacketFilter(ctx, d.PacketFilterID)
+		if err != nil && !sacloud.IsNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("error deleting packet filter[id:%s]: %v", d.PacketFilterID, err))
+		} else {
+			log.Infof("Removed packet filter.")
+		}
+	}
+
+	if d.SSHKeyResourceID != "" && len(errs) == 0 {
+		err := d.getClient().DeleteSSHKey(ctx, d.SSHKeyResourceID)
+		if err != nil && !sacloud.IsNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("error deleting ssh key[id:%s]: %v", d.SSHKeyResourceID, err))
+		} else {
+			log.Infof("Removed ssh key.")
+		}
+	}
+
+	return errs.errorOrNil()
+}
+
+// removeServer サーバと接続されたディスクを削除し、削除されたことを確認する
+func (d *Driver) removeServer(ctx context.Context) error {
+	client := d.getClient()
+	id := types.StringID(d.ID)
+	diskIDs := d.diskIDs()
+
+	if !id.IsEmpty() {
+		sv, err := client.ReadServer(ctx, id)
+		switch {
+		case sacloud.IsNotFoundError(err):
+			log.Infof("Server[id:%s] is already removed", id)
+		case err != nil:
+			return fmt.Errorf("error reading server[id:%s]: %v", id, err)
+		default:
+			if d.ArchiveOnRemove && !types.StringID(d.DiskID).IsEmpty() {
+				// アーカイブの作成に失敗した場合はデータを残すため削除しない
+				if err := d.archiveDisk(ctx, !sv.InstanceStatus.IsDown()); err != nil {
+					return err
+				}
+			} else if !sv.InstanceStatus.IsDown() {
+				if err := client.PowerOff(ctx, d.ID, true); err != nil {
+					return fmt.Errorf("error stopping server[id:%s]: %v", id, err)
+				}
+				if err := d.waitForServerByState(ctx, state.Stopped); err != nil {
+					return fmt.Errorf("error stopping server[id:%s]: %v", id, err)
+				}
+			}
+
+			var connected []types.ID
+			for _, disk := range sv.Disks {
+				connected = append(connected, disk.ID)
+			}
+			diskIDs = appendIDIfAbsent(diskIDs, connected...)
+
+			if err := client.DeleteWithDisks(ctx, id, connected); err != nil && !sacloud.IsNotFoundError(err) {
+				return fmt.Errorf("error deleting server[id:%s]: %v", id, err)
+			}
+			if err := d.waitForDeletion(ctx, fmt.Sprintf("server[id:%s]", id), func(ctx context.Context) (bool, error) {
+				return client.IsExistsServer(ctx, id)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// disks which are not connected to the server(e.g. disconnected manually) are deleted individually
+	var errs multiError
+	for _, diskID := range diskIDs {
+		diskID := diskID
+		exists, err := client.IsExistsDisk(ctx, diskID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading disk[id:%s]: %v", diskID, err))
+			continue
+		}
+		if exists {
+			if err := client.DeleteDisk(ctx, diskID); err != nil && !sacloud.IsNotFoundError(err) {
+				errs = append(errs, fmt.Errorf("error deleting disk[id:%s]: %v", diskID, err))
+				continue
+			}
+		}
+		if err := d.waitForDeletion(ctx, fmt.Sprintf("disk[id:%s]", diskID), func(ctx context.Context) (bool, error) {
+			return client.IsExistsDisk(ctx, diskID)
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// diskIDs ドライバが作成したディスクのIDを返す
+func (d *Driver) diskIDs() []types.ID {
+	var ids []types.ID
+	if id := types.StringID(d.DiskID); !id.IsEmpty() {
+		ids = append(ids, id)
+	}
+	for _, strID := range d.AdditionalDiskIDs {
+		if id := types.StringID(strID); !id.IsEmpty() {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func appendIDIfAbsent(ids []types.ID, values ...types.ID) []types.ID {
+	for _, v := range values {
+		exists := false
+		for _, id := range ids {
+			if id == v {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
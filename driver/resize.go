@@ -0,0 +1,294 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// ResizeOptions Resizeで変更する値、ゼロ値の項目は変更しない
+type ResizeOptions struct {
+	Core   int
+	Memory int
+	// GPU nilの場合は変更しない、0を指定した場合はGPUプランから通常のプランに変更する
+	GPU            *int
+	Commitment     string
+	PlanGeneration int
+
+	// DiskSize 拡張後のディスクサイズ(GB)、ディスクは指定サイズでコピーして置き換える
+	DiskSize int
+	// ExpandPartition ディスクの拡張後にパーティションも拡張するか
+	ExpandPartition bool
+}
+
+// ResizeMachine ストアに保存されたマシンのプランやディスクを変更し、変更後のサーバ/ディスクのIDをストアに保存する
+func ResizeMachine(storePath, machineName string, opts *ResizeOptions) error {
+	configPath := filepath.Join(storePath, "machines", machineName, "config.json")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Driver以外の項目はそのまま書き戻す
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("unable to read config of machine %q: %v", machineName, err)
+	}
+	var driverName string
+	if err := json.Unmarshal(config["DriverName"], &driverName); err != nil || driverName != "sakuracloud" {
+		return fmt.Errorf("machine %q is not created by sakuracloud driver", machineName)
+	}
+
+	d := NewDriver(machineName, storePath).(*Driver)
+	if err := json.Unmarshal(config["Driver"], d); err != nil {
+		return fmt.Errorf("unable to read driver config of machine %q: %v", machineName, err)
+	}
+
+	// プラン変更後はサーバIDが変わるため、途中で失敗した場合も変更済みのIDを保存する
+	resizeErr := d.Resize(opts)
+	if err := saveDriverConfig(configPath, config, d); err != nil {
+		if resizeErr != nil {
+			return multiError{resizeErr, err}
+		}
+		return fmt.Errorf("error saving config of machine %q: %v", machineName, err)
+	}
+	return resizeErr
+}
+
+// Resize サーバのプランの変更やディスクの拡張を行う
+//
+// 変更にはサーバの停止が必要なため、起動中の場合は一度シャットダウンし、変更後に起動する。
+// 変更に失敗した場合も停止したままにならないよう起動する。
+func (d *Driver) Resize(opts *ResizeOptions) (err error) {
+	ctx := context.Background()
+	client := d.getClient()
+
+	sv, err := client.ReadServer(ctx, types.StringID(d.ID))
+	if err != nil {
+		return fmt.Errorf("error reading server[id:%s]: %v", d.ID, err)
+	}
+
+	core, memory, gpu := sv.CPU, sv.GetMemoryGB(), sv.GPU
+	commitment, generation := sv.ServerPlanCommitment, sv.ServerPlanGeneration
+	if opts.Core > 0 {
+		core = opts.Core
+	}
+	if opts.Memory > 0 {
+		memory = opts.Memory
+	}
+	if opts.GPU != nil {
+		if *opts.GPU < 0 {
+			return fmt.Errorf("invalid gpu: %d", *opts.GPU)
+		}
+		gpu = *opts.GPU
+	}
+	if opts.Commitment != "" {
+		commitment = (&sakuraServerConfig{Commitment: opts.Commitment}).commitment()
+	}
+	if opts.PlanGeneration > 0 {
+		generation = types.EPlanGeneration(opts.PlanGeneration)
+	}
+	changePlan := core != sv.CPU || memory != sv.GetMemoryGB() || gpu != sv.GPU ||
+		commitment != sv.ServerPlanCommitment || generation != sv.ServerPlanGeneration
+
+	if !changePlan && opts.DiskSize == 0 {
+		return fmt.Errorf("nothing to resize: server[id:%s] already has the specified plan", d.ID)
+	}
+	if changePlan {
+		valid, err := client.IsValidPlan(ctx, core, memory, gpu, commitment, generation)
+		if !valid || err != nil {
+			return fmt.Errorf("invalid plan: core/memory/gpu/commitment/generation : %v", err)
+		}
+	}
+
+	wasUp := sv.InstanceStatus.IsUp()
+	if wasUp {
+		log.Infof("Stopping server[id:%s] to resize...", d.ID)
+		if err := client.PowerOff(ctx, d.ID, false); err != nil {
+			return err
+		}
+		if err := d.waitForServerByState(ctx, state.Stopped); err != nil {
+			return fmt.Errorf("error stopping server[id:%s]: %v (use 'docker-machine stop' or 'docker-machine kill' first)", d.ID, err)
+		}
+		// プラン変更後のサーバを起動するため、実行時のd.IDを参照する
+		defer func() {
+			startErr := d.startResizedServer(ctx)
+			switch {
+			case startErr == nil:
+			case err == nil:
+				err = startErr
+			default:
+				err = multiError{err, startErr}
+			}
+		}()
+	}
+
+	if changePlan {
+		log.Infof("Changing plan of server[id:%s] to core:%d/memory:%dGB/gpu:%d...", d.ID, core, memory, gpu)
+		newID, err := client.ChangePlan(ctx, sv.ID, core, memory, gpu, commitment, generation)
+		if err != nil {
+			return fmt.Errorf("error changing plan of server[id:%s]: %v", d.ID, err)
+		}
+		log.Infof("Server ID is changed: %s -> %s", d.ID, newID)
+		d.ID = newID.String()
+	}
+
+	if opts.DiskSize > 0 {
+		if err := d.expandDisk(ctx, opts.DiskSize, opts.ExpandPartition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startResizedServer Resizeのために停止したサーバを起動する
+func (d *Driver) startResizedServer(ctx context.Context) error {
+	if err := d.getClient().PowerOn(ctx, d.ID); err != nil {
+		return fmt.Errorf("error starting server[id:%s]: %v", d.ID, err)
+	}
+	if err := d.waitForServerByState(ctx, state.Running); err != nil {
+		return fmt.Errorf("error starting server[id:%s]: %v", d.ID, err)
+	}
+	return nil
+}
+
+// expandDisk ディスク(DiskID)を指定サイズでコピーし、元のディスクと置き換える
+func (d *Driver) expandDisk(ctx context.Context, sizeGB int, expandPartition bool) error {
+	client := d.getClient()
+
+	source, err := client.ReadDisk(ctx, types.StringID(d.DiskID))
+	if err != nil {
+		return fmt.Errorf("error reading disk[id:%s]: %v", d.DiskID, err)
+	}
+	if sizeGB <= source.GetSizeGB() {
+		return fmt.Errorf("disk size must be larger than current size: %dGB", source.GetSizeGB())
+	}
+	if err := d.validateExpandedDiskSize(ctx, source, sizeGB); err != nil {
+		return err
+	}
+
+	log.Infof("Copying disk[id:%s] to a new %dGB disk, this may take a while...", source.ID, sizeGB)
+	disk, err := client.CloneDisk(ctx, source, sizeGB)
+	if disk != nil {
+		// 置き換えが完了するまではAdditionalDiskIDsで管理し、途中で失敗した場合もdocker-machine rmで削除されるようにする
+		d.AdditionalDiskIDs = append(d.AdditionalDiskIDs, disk.ID.String())
+	}
+	if err != nil {
+		if disk != nil {
+			d.discardDisk(ctx, disk.ID)
+			return fmt.Errorf("error copying disk[id:%s] to disk[id:%s]: %v", source.ID, disk.ID, err)
+		}
+		return fmt.Errorf("error copying disk[id:%s]: %v", source.ID, err)
+	}
+	if expandPartition {
+		log.Infof("Expanding partition of disk[id:%s]...", disk.ID)
+		if err := client.ResizePartition(ctx, disk.ID); err != nil {
+			d.discardDisk(ctx, disk.ID)
+			return fmt.Errorf("error expanding partition of disk[id:%s]: %v", disk.ID, err)
+		}
+	}
+
+	sv, err := client.ReadServer(ctx, types.StringID(d.ID))
+	if err != nil {
+		d.discardDisk(ctx, disk.ID)
+		return fmt.Errorf("error reading server[id:%s]: %v", d.ID, err)
+	}
+
+	var original []types.ID
+	for _, connected := range sv.Disks {
+		original = append(original, connected.ID)
+	}
+	replaced := []types.ID{disk.ID}
+	for _, id := range original {
+		if id != source.ID {
+			replaced = append(replaced, id)
+		}
+	}
+	if err := d.connectDisksInOrder(ctx, sv.ID, replaced); err != nil {
+		// 元のディスクの構成に戻す
+		if restoreErr := d.connectDisksInOrder(ctx, sv.ID, original); restoreErr != nil {
+			return fmt.Errorf("%v, and restoring disks is also failed, please connect disks %v to server[id:%s] in this order manually: %v",
+				err, original, sv.ID, restoreErr)
+		}
+		d.discardDisk(ctx, disk.ID)
+		return err
+	}
+	d.DiskID = disk.ID.String()
+	d.AdditionalDiskIDs = removeString(d.AdditionalDiskIDs, disk.ID.String())
+
+	if err := client.DeleteDisk(ctx, source.ID); err != nil && !sacloud.IsNotFoundError(err) {
+		log.Warnf("Failed to delete old disk[id:%s], it will be deleted with the machine: %v", source.ID, err)
+		d.AdditionalDiskIDs = append(d.AdditionalDiskIDs, source.ID.String())
+	}
+	return nil
+}
+
+// connectDisksInOrder サーバに接続されたディスクを指定の順序にする
+//
+// 最初に接続されたディスクが起動ディスクとなるため、順序が異なる位置以降のディスクを後ろから切断し、指定の順序で接続し直す。
+// 後ろから切断するため、途中で失敗した場合も接続されたディスクは元の順序の先頭部分となる。
+func (d *Driver) connectDisksInOrder(ctx context.Context, serverID types.ID, diskIDs []types.ID) error {
+	client := d.getClient()
+	sv, err := client.ReadServer(ctx, serverID)
+	if err != nil {
+		return fmt.Errorf("error reading server[id:%s]: %v", serverID, err)
+	}
+
+	keep := 0
+	for keep < len(sv.Disks) && keep < len(diskIDs) && sv.Disks[keep].ID == diskIDs[keep] {
+		keep++
+	}
+	for i := len(sv.Disks) - 1; i >= keep; i-- {
+		if err := client.DisconnectDisk(ctx, sv.Disks[i].ID); err != nil {
+			return fmt.Errorf("error disconnecting disk[id:%s]: %v", sv.Disks[i].ID, err)
+		}
+	}
+	for _, id := range diskIDs[keep:] {
+		if err := client.ConnectDisk(ctx, id, serverID); err != nil {
+			return fmt.Errorf("error connecting disk[id:%s] to server[id:%s]: %v", id, serverID, err)
+		}
+	}
+	return nil
+}
+
+// discardDisk Resizeの途中で作成したディスクを削除する
+//
+// 削除できなかった場合はAdditionalDiskIDsに残し、docker-machine rmで削除されるようにする
+func (d *Driver) discardDisk(ctx context.Context, id types.ID) {
+	if err := d.getClient().DeleteDisk(ctx, id); err != nil && !sacloud.IsNotFoundError(err) {
+		log.Warnf("Failed to delete disk[id:%s], it will be deleted with the machine: %v", id, err)
+		return
+	}
+	d.AdditionalDiskIDs = removeString(d.AdditionalDiskIDs, id.String())
+}
+
+func removeString(values []string, v string) []string {
+	var results []string
+	for _, s := range values {
+		if s != v {
+			results = append(results, s)
+		}
+	}
+	return results
+}
+
+func (d *Driver) validateExpandedDiskSize(ctx context.Context, source *sacloud.Disk, sizeGB int) error {
+	sizes, err := d.getClient().AvailableDiskSizes(ctx, source.DiskPlanID)
+	if err != nil {
+		// ディスクプランが参照できない場合はディスク作成時のエラーに任せる
+		return nil
+	}
+	for _, size := range sizes {
+		if size == sizeGB {
+			return nil
+		}
+	}
+	return fmt.Errorf("disk size must be set to one of %v", sizes)
+}
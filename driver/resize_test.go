@@ -0,0 +1,146 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/fake"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createFakeMachine fakeドライバでサーバを作成し、docker-machineと同様にconfig.jsonを保存する
+func createFakeMachine(t *testing.T, flags map[string]interface{}) *Driver {
+	d := newFakeDriver(t, flags)
+	require.NoError(t, d.Create())
+	saveFakeMachine(t, d)
+
+	t.Cleanup(func() {
+		assert.NoError(t, loadFakeMachine(t, d).Remove())
+	})
+	return d
+}
+
+func connectedDiskIDs(t *testing.T, d *Driver) []string {
+	sv, err := sacloud.NewServerOp(nil).Read(context.Background(), d.Client.Zone, types.StringID(d.ID))
+	require.NoError(t, err)
+	var ids []string
+	for _, disk := range sv.Disks {
+		ids = append(ids, disk.ID.String())
+	}
+	return ids
+}
+
+func TestResizeMachine_ChangePlan(t *testing.T) {
+	d := createFakeMachine(t, nil)
+
+	gpu := 0
+	require.NoError(t, ResizeMachine(d.StorePath, d.GetMachineName(), &ResizeOptions{Core: 2, Memory: 4, GPU: &gpu}))
+
+	loaded := loadFakeMachine(t, d)
+	assert.NotEqual(t, d.ID, loaded.ID, "new server ID should be saved")
+	assert.Equal(t, d.DiskID, loaded.DiskID)
+
+	serverOp := sacloud.NewServerOp(nil)
+	_, err := serverOp.Read(context.Background(), d.Client.Zone, types.StringID(d.ID))
+	assert.True(t, sacloud.IsNotFoundError(err), "old server should be deleted")
+
+	sv, err := serverOp.Read(context.Background(), d.Client.Zone, types.StringID(loaded.ID))
+	require.NoError(t, err)
+	assert.Equal(t, 2, sv.CPU)
+	assert.Equal(t, 4, sv.GetMemoryGB())
+	assert.True(t, sv.InstanceStatus.IsUp(), "server should be booted again")
+	assert.Equal(t, []string{d.DiskID}, connectedDiskIDs(t, loaded))
+}
+
+// failingServerOp プラン変更でエラーを返すServerAPI
+type failingServerOp struct {
+	sacloud.ServerAPI
+}
+
+func (o *failingServerOp) ChangePlan(ctx context.Context, zone string, id types.ID, plan *sacloud.ServerChangePlanRequest) (*sacloud.Server, error) {
+	return nil, errors.New("failed to change plan")
+}
+
+func TestResizeMachine_ChangePlanFailure(t *testing.T) {
+	d := createFakeMachine(t, nil)
+	replaceFakeOp(t, fake.ResourceServer, &failingServerOp{ServerAPI: fake.NewServerOp()}, fake.NewServerOp())
+
+	err := ResizeMachine(d.StorePath, d.GetMachineName(), &ResizeOptions{Core: 2})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error changing plan")
+
+	loaded := loadFakeMachine(t, d)
+	assert.Equal(t, d.ID, loaded.ID)
+	sv, err := sacloud.NewServerOp(nil).Read(context.Background(), d.Client.Zone, types.StringID(loaded.ID))
+	require.NoError(t, err)
+	assert.True(t, sv.InstanceStatus.IsUp(), "server should be booted again even if resizing fails")
+}
+
+func TestResizeMachine_ExpandDisk(t *testing.T) {
+	d := createFakeMachine(t, map[string]interface{}{
+		"sakuracloud-additional-disk": []string{"size=40,plan=hdd"},
+	})
+	require.Len(t, d.AdditionalDiskIDs, 1)
+
+	require.NoError(t, ResizeMachine(d.StorePath, d.GetMachineName(), &ResizeOptions{DiskSize: 40, ExpandPartition: true}))
+
+	loaded := loadFakeMachine(t, d)
+	assert.Equal(t, d.ID, loaded.ID)
+	assert.NotEqual(t, d.DiskID, loaded.DiskID, "new disk ID should be saved")
+	assert.Equal(t, d.AdditionalDiskIDs, loaded.AdditionalDiskIDs)
+	assert.Equal(t, []string{loaded.DiskID, d.AdditionalDiskIDs[0]}, connectedDiskIDs(t, loaded),
+		"new disk should be connected as the boot disk")
+
+	diskOp := sacloud.NewDiskOp(nil)
+	disk, err := diskOp.Read(context.Background(), d.Client.Zone, types.StringID(loaded.DiskID))
+	require.NoError(t, err)
+	assert.Equal(t, 40, disk.GetSizeGB())
+	_, err = diskOp.Read(context.Background(), d.Client.Zone, types.StringID(d.DiskID))
+	assert.True(t, sacloud.IsNotFoundError(err), "old disk should be deleted")
+}
+
+func TestResizeMachine_ExpandDiskFailure(t *testing.T) {
+	d := createFakeMachine(t, nil)
+
+	// 存在しないディスクをサーバの接続情報に追加し、ディスクの切断で失敗させる
+	missingDiskID := types.ID(999999999999)
+	setFakeConnectedDisks(t, d, append(connectedDiskIDs(t, d), missingDiskID.String()))
+	before := connectedDiskIDs(t, d)
+
+	err := ResizeMachine(d.StorePath, d.GetMachineName(), &ResizeOptions{Core: 2, DiskSize: 40})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error disconnecting disk[id:999999999999]")
+
+	loaded := loadFakeMachine(t, d)
+	assert.NotEqual(t, d.ID, loaded.ID, "changed server ID should be saved even if resizing fails")
+	assert.Equal(t, d.DiskID, loaded.DiskID)
+	assert.Empty(t, loaded.AdditionalDiskIDs)
+	assert.Equal(t, before, connectedDiskIDs(t, loaded), "original disks should be kept connected")
+	sv, err := sacloud.NewServerOp(nil).Read(context.Background(), d.Client.Zone, types.StringID(loaded.ID))
+	require.NoError(t, err)
+	assert.True(t, sv.InstanceStatus.IsUp(), "server should be booted again even if resizing fails")
+
+	// コピーしたディスクは削除される
+	searched, err := sacloud.NewDiskOp(nil).Find(context.Background(), d.Client.Zone, &sacloud.FindCondition{})
+	require.NoError(t, err)
+	for _, disk := range searched.Disks {
+		assert.Contains(t, []string{d.DiskID}, disk.ID.String(), "copied disk should be deleted")
+	}
+
+	setFakeConnectedDisks(t, loaded, []string{d.DiskID})
+}
+
+// setFakeConnectedDisks fakeドライバのデータストア上でサーバに接続されたディスクを書き換える
+func setFakeConnectedDisks(t *testing.T, d *Driver, diskIDs []string) {
+	sv, err := sacloud.NewServerOp(nil).Read(context.Background(), d.Client.Zone, types.StringID(d.ID))
+	require.NoError(t, err)
+	sv.Disks = nil
+	for _, id := range diskIDs {
+		sv.Disks = append(sv.Disks, &sacloud.ServerConnectedDisk{ID: types.StringID(id)})
+	}
+	fake.DataStore.Put(fake.ResourceServer, d.Client.Zone, sv.ID, sv)
+}
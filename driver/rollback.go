@@ -0,0 +1,131 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// createdResources Create中に作成したリソース
+type createdResources struct {
+	ServerID       types.ID
+	DiskIDs        []types.ID
+	PacketFilterID string
+	SSHKeyID       string
+}
+
+func (r *createdResources) String() string {
+	var resources []string
+	if !r.ServerID.IsEmpty() {
+		resources = append(resources, fmt.Sprintf("server[id:%s]", r.ServerID))
+	}
+	for _, id := range r.DiskIDs {
+		resources = append(resources, fmt.Sprintf("disk[id:%s]", id))
+	}
+	if r.PacketFilterID != "" {
+		resources = append(resources, fmt.Sprintf("packet-filter[id:%s]", r.PacketFilterID))
+	}
+	if r.SSHKeyID != "" {
+		resources = append(resources, fmt.Sprintf("ssh-key[id:%s]", r.SSHKeyID))
+	}
+	return strings.Join(resources, ", ")
+}
+
+func (r *createdResources) isEmpty() bool {
+	return r.ServerID.IsEmpty() && len(r.DiskIDs) == 0 && r.PacketFilterID == "" && r.SSHKeyID == ""
+}
+
+// rollback Createに失敗した際に作成済みのリソースを削除する
+//
+// 削除できなかったリソースがある場合はエラーを返す
+func (d *Driver) rollback(ctx context.Context, created *createdResources) error {
+	if created.isEmpty() {
+		return nil
+	}
+	if d.serverConfig.KeepOnFailure {
+		d.keepResources(created)
+		return nil
+	}
+
+	log.Infof("Rolling back resources created before the failure: %s", created)
+	client := d.getClient()
+	var errs []string
+
+	if !created.ServerID.IsEmpty() {
+		// connected disks are deleted with the server
+		id := created.ServerID
+		err := client.ForceDelete(ctx, id)
+		if err == nil {
+			err = d.waitForDeletion(ctx, fmt.Sprintf("server[id:%s]", id), func(ctx context.Context) (bool, error) {
+				return client.IsExistsServer(ctx, id)
+			})
+		}
+		if err != nil && !sacloud.IsNotFoundError(err) {
+			errs = append(errs, fmt.Sprintf("server[id:%s]: %s", id, err))
+		}
+	}
+	for _, id := range created.DiskIDs {
+		// disks deleted with the server may still be being deleted, so only the remaining ones are deleted
+		id := id
+		exists, err := client.IsExistsDisk(ctx, id)
+		if err == nil && exists {
+			err = client.DeleteDisk(ctx, id)
+		}
+		if err == nil {
+			err = d.waitForDeletion(ctx, fmt.Sprintf("disk[id:%s]", id), func(ctx context.Context) (bool, error) {
+				return client.IsExistsDisk(ctx, id)
+			})
+		}
+		if err != nil && !sacloud.IsNotFoundError(err) {
+			errs = append(errs, fmt.Sprintf("disk[id:%s]: %s", id, err))
+		}
+	}
+	if created.PacketFilterID != "" {
+		if err := client.DeletePacketFilter(ctx, created.PacketFilterID); err != nil && !sacloud.IsNotFoundError(err) {
+			errs = append(errs, fmt.Sprintf("packet-filter[id:%s]: %s", created.PacketFilterID, err))
+		}
+	}
+
+	if created.SSHKeyID != "" {
+		if err := client.DeleteSSHKey(ctx, created.SSHKeyID); err != nil && !sacloud.IsNotFoundError(err) {
+			errs = append(errs, fmt.Sprintf("ssh-key[id:%s]: %s", created.SSHKeyID, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to roll back some resources, please delete them manually: %s", strings.Join(errs, ", "))
+	}
+	log.Infof("Rolled back resources.")
+	return nil
+}
+
+// keepResources 作成済みのリソースのIDをDriverに設定して保存し、docker-machine rmで削除できるようにする
+func (d *Driver) keepResources(created *createdResources) {
+	if !created.ServerID.IsEmpty() {
+		d.ID = created.ServerID.String()
+	}
+	if len(created.DiskIDs) > 0 {
+		d.DiskID = created.DiskIDs[0].String()
+		d.AdditionalDiskIDs = nil
+		for _, id := range created.DiskIDs[1:] {
+			d.AdditionalDiskIDs = append(d.AdditionalDiskIDs, id.String())
+		}
+	}
+	if created.PacketFilterID != "" {
+		d.PacketFilterID = created.PacketFilterID
+	}
+	if created.SSHKeyID != "" {
+		d.SSHKeyResourceID = created.SSHKeyID
+	}
+
+	log.Warnf("Keeping resources created before the failure: %s", created)
+	if err := d.saveStoredConfig(); err != nil {
+		log.Warnf("Failed to save IDs of the kept resources to the machine config, please delete them manually: %v", err)
+		return
+	}
+	log.Warnf("Use 'docker-machine rm %s' to delete them", d.GetMachineName())
+}
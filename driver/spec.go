@@ -2,27 +2,37 @@ package driver
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
 )
 
 var (
-	defaultRegion          = "is1b"   // 石狩第2ゾーン
-	defaultOSType          = "coreos" // OSタイプ
-	defaultCore            = 1        // デフォルトコア数
-	defaultMemorySize      = 1        // デフォルトメモリサイズ
-	defaultDiskPlan        = "ssd"    // ディスクプラン(ssd/hdd)
-	defaultDiskSize        = 20       // 20GB
-	defaultDiskConnection  = "virtio" // ディスク接続ドライバ
-	defaultInterfaceDriver = "virtio" // NIC接続ドライバ
-	defaultPacketFilter    = ""
-	defaultEnablePWAuth    = false
+	defaultRegion               = "is1b"   // 石狩第2ゾーン
+	defaultOSType               = "ubuntu" // OSタイプ
+	defaultCore                 = 1        // デフォルトコア数
+	defaultMemorySize           = 1        // デフォルトメモリサイズ
+	defaultCommitment           = "standard"
+	defaultPlanGeneration       = 0        // 0の場合は自動選択
+	defaultDiskPlan             = "ssd"    // ディスクプラン(ssd/hdd)
+	defaultDiskSize             = 20       // 20GB
+	defaultDiskConnection       = "virtio" // ディスク接続ドライバ
+	defaultInterfaceDriver      = "virtio" // NIC接続ドライバ
+	defaultPacketFilter         = ""
+	defaultEnablePWAuth         = false
+	defaultWaitTimeout          = 600 // サーバの状態変化を待つ秒数
+	defaultWaitInterval         = 5   // サーバの状態を確認する間隔(秒)
+	defaultStartupScriptTimeout = 900 // スタートアップスクリプト実行後のシャットダウンを待つ秒数
+	maxDescriptionLen           = 512
 )
 
 var (
-	allowOSTypes          = []string{"rancheros", "centos", "ubuntu", "coreos"}
+	allowOSTypes          = []string{"ubuntu", "ubuntu2004", "ubuntu2204", "debian", "centos", "rancheros", "coreos"}
+	allowCommitments      = []string{"standard", "dedicatedcpu"}
+	allowPlanGenerations  = []int{0, 100, 200}
 	allowDiskPlans        = []string{"hdd", "ssd"}
 	allowSSDSizes         = []int{20, 40, 100, 250, 500, 1024, 2048, 4096}
 	allowHDDSizes         = []int{40, 60, 80, 100, 250, 500, 750, 1024, 2048, 4096}
@@ -36,6 +46,8 @@ type sakuraServerConfig struct {
 	Core            int
 	Memory          int
 	GPU             int
+	Commitment      string
+	PlanGeneration  int
 	DiskPlan        string
 	DiskSize        int
 	DiskConnection  string
@@ -44,6 +56,29 @@ type sakuraServerConfig struct {
 	PacketFilter    string
 	EnablePWAuth    bool
 	EnginePort      int
+	PrivateNICs     []*privateNICConfig
+	PrivateOnly     bool
+	Gateway         string
+
+	ManagedPacketFilter *packetFilterConfig
+	KeepOnFailure       bool
+	AdditionalDisks     []*additionalDiskConfig
+	SourceArchiveID     string
+	SourceDiskID        string
+	Tags                types.Tags
+	MachineTags         types.Tags // マシンを識別するために自動的に付与するタグ
+	Description         string
+	IconID              string
+	ServerID            string // 既存のサーバを取り込む場合のサーバID
+	DryRun              bool   // リソースを作成せずに作成予定のリソースを表示する
+	StartupScripts      []*startupScriptConfig
+	KeepStartupScripts  bool // スタートアップスクリプトをサーバ作成後も削除せずに残すか
+	// StartupScriptTimeout スタートアップスクリプト実行後のシャットダウンを待つ秒数
+	StartupScriptTimeout int
+	SSHKeyIDs            []string // 公開鍵を登録するSSHキーリソースのID
+	RegisterSSHKey       bool     // マシンの公開鍵をSSHキーリソースとして登録するか
+
+	resolvedOSType *osTypeInfo // os-typeにアーカイブ名/タグを指定した場合に検索したアーカイブから推測したOSの情報
 }
 
 var defaultServerConfig = &sakuraServerConfig{
@@ -57,50 +92,91 @@ var defaultServerConfig = &sakuraServerConfig{
 }
 
 func (c *sakuraServerConfig) SSHUserName() string {
-	switch c.OSType {
-	case "ubuntu":
-		return "ubuntu"
-	case "rancheros":
-		return "rancher"
-	case "coreos":
-		return "core"
-	default:
-		return "root"
+	if info := c.osTypeInfo(); info != nil {
+		return info.SSHUser
 	}
+	return "root"
 }
 
 func (c *sakuraServerConfig) IsUbuntu() bool {
-	return c.OSType == "ubuntu"
+	info := c.osTypeInfo()
+	return info != nil && info.Family == osFamilyUbuntu
 }
-func (c *sakuraServerConfig) IsCentOS() bool {
-	return c.OSType == "centos"
+
+// IsRHEL CentOSなどRHEL系のOSであるか
+func (c *sakuraServerConfig) IsRHEL() bool {
+	info := c.osTypeInfo()
+	return info != nil && info.Family == osFamilyRHEL
 }
 
 func (c *sakuraServerConfig) IsNeedWaitingRestart() bool {
-	return c.IsUbuntu() || c.IsCentOS()
+	// スタートアップスクリプト実行後にシャットダウンされるため再起動を待つ
+	return c.IsUbuntu() || c.IsRHEL()
+}
+
+func (c *sakuraServerConfig) commitment() types.ECommitment {
+	if c.Commitment == "dedicatedcpu" {
+		return types.Commitments.DedicatedCPU
+	}
+	return types.Commitments.Standard
+}
+
+func (c *sakuraServerConfig) planGeneration() types.EPlanGeneration {
+	return types.EPlanGeneration(c.PlanGeneration)
 }
 
 func (c *sakuraServerConfig) Validate() error {
 	// os-type
-	if !c.isStrInValue(c.OSType, allowOSTypes...) {
-		return fmt.Errorf("%q must be set to one of [%s]", "--sakuracloud-os-type", strings.Join(allowOSTypes, "/"))
+	if isDynamicOSType(c.OSType) {
+		if _, err := dynamicOSTypeFilter(c.OSType); err != nil {
+			return err
+		}
+		if c.SourceArchiveID != "" || c.SourceDiskID != "" {
+			return fmt.Errorf("%q must be set to one of [%s] when %q or %q is specified", "--sakuracloud-os-type",
+				strings.Join(allowOSTypes, "/"), "--sakuracloud-source-archive-id", "--sakuracloud-source-disk-id")
+		}
+	} else if !c.isStrInValue(c.OSType, allowOSTypes...) {
+		return fmt.Errorf("%q must be set to one of [%s], %q or %q", "--sakuracloud-os-type",
+			strings.Join(allowOSTypes, "/"), osTypeNamePrefix+"<archive name>", osTypeTagPrefix+"<tag>[,<tag>...]")
 	}
 
-	// disk-plan
-	if !c.isStrInValue(c.DiskPlan, allowDiskPlans...) {
-		return fmt.Errorf("%q must be set to one of [%s]", "--sakuracloud-disk-plan", strings.Join(allowDiskPlans, "/"))
+	// source archive/disk
+	if c.SourceArchiveID != "" && c.SourceDiskID != "" {
+		return fmt.Errorf("%q and %q are mutually exclusive", "--sakuracloud-source-archive-id", "--sakuracloud-source-disk-id")
+	}
+	if c.SourceArchiveID != "" && types.StringID(c.SourceArchiveID).IsEmpty() {
+		return fmt.Errorf("%q is invalid: %s", "--sakuracloud-source-archive-id", c.SourceArchiveID)
+	}
+	if c.SourceDiskID != "" && types.StringID(c.SourceDiskID).IsEmpty() {
+		return fmt.Errorf("%q is invalid: %s", "--sakuracloud-source-disk-id", c.SourceDiskID)
 	}
 
-	// disk-size(per disk-plan)
-	var allowDiskSizes []int
-	switch c.DiskPlan {
-	case "ssd":
-		allowDiskSizes = allowSSDSizes
-	case "hdd":
-		allowDiskSizes = allowHDDSizes
+	// tags/description/icon
+	for _, tag := range c.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("%q must not be empty", "--sakuracloud-tag")
+		}
+	}
+	if len(c.Description) > maxDescriptionLen {
+		return fmt.Errorf("%q must be %d characters or less", "--sakuracloud-description", maxDescriptionLen)
+	}
+	if c.IconID != "" && types.StringID(c.IconID).IsEmpty() {
+		return fmt.Errorf("%q is invalid: %s", "--sakuracloud-icon-id", c.IconID)
 	}
-	if !c.isIntInValue(c.DiskSize, allowDiskSizes...) {
-		return fmt.Errorf("%q must be set to one of [20(SSD)/40/60(HDD)/80(HDD)/100/250/500/750(HDD)/1024/2048/4096]", "--sakuracloud-disk-size")
+
+	// commitment
+	if !c.isStrInValue(c.Commitment, allowCommitments...) {
+		return fmt.Errorf("%q must be set to one of [%s]", "--sakuracloud-commitment", strings.Join(allowCommitments, "/"))
+	}
+
+	// plan-generation
+	if !c.isIntInValue(c.PlanGeneration, allowPlanGenerations...) {
+		return fmt.Errorf("%q must be set to one of [0(auto)/100/200]", "--sakuracloud-plan-generation")
+	}
+
+	// disk-plan
+	if !c.isStrInValue(c.DiskPlan, allowDiskPlans...) {
+		return fmt.Errorf("%q must be set to one of [%s]", "--sakuracloud-disk-plan", strings.Join(allowDiskPlans, "/"))
 	}
 
 	// disk-connection
@@ -113,6 +189,76 @@ func (c *sakuraServerConfig) Validate() error {
 		return fmt.Errorf("%q must be set to one of [%s]", "--sakuracloud-interface-driver", strings.Join(allowInterfaceDrivers, "/"))
 	}
 
+	// additional disks
+	if len(c.AdditionalDisks) > maxAdditionalDisks {
+		return fmt.Errorf("%q can be specified up to %d times", "--sakuracloud-additional-disk", maxAdditionalDisks)
+	}
+	for _, disk := range c.AdditionalDisks {
+		if !c.isStrInValue(disk.Plan, allowDiskPlans...) {
+			return fmt.Errorf("plan of %q must be set to one of [%s]", "--sakuracloud-additional-disk", strings.Join(allowDiskPlans, "/"))
+		}
+		if !c.isStrInValue(disk.Connection, allowDiskConnections...) {
+			return fmt.Errorf("connection of %q must be set to one of [%s]", "--sakuracloud-additional-disk", strings.Join(allowDiskConnections, "/"))
+		}
+	}
+
+	// packet filter
+	if c.ManagedPacketFilter != nil {
+		if c.PacketFilter != "" {
+			return fmt.Errorf("%q and %q cannot be specified together", "--sakuracloud-packet-filter", "--sakuracloud-create-packet-filter")
+		}
+		if err := c.ManagedPacketFilter.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// private nics
+	if err := c.validatePrivateNICs(); err != nil {
+		return err
+	}
+	if len(c.additionalNICs()) > maxAdditionalNICs {
+		return fmt.Errorf("%q can be specified up to %d times", "--sakuracloud-switch-id", maxAdditionalNICs)
+	}
+
+	return nil
+}
+
+// validateResolved OSの種類に依存する設定を検証する
+//
+// name:/tag:形式のos-typeはアーカイブを検索するまでOSの種類が決まらないため、resolveArchiveの後に検証する
+func (c *sakuraServerConfig) validateResolved() error {
+	if len(c.additionalNICs()) > 0 && !c.IsUbuntu() && !c.IsRHEL() {
+		return fmt.Errorf("additional NICs are only supported with %q set to ubuntu or centos", "--sakuracloud-os-type")
+	}
+	return nil
+}
+
+// validatePrivateNICs プライベートネットワークに接続するNICとprivate-onlyモードの設定を検証する
+func (c *sakuraServerConfig) validatePrivateNICs() error {
+	for _, nic := range c.PrivateNICs {
+		if err := nic.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.PrivateOnly {
+		if len(c.PrivateNICs) == 0 {
+			return fmt.Errorf("%q is required when %q is specified", "--sakuracloud-switch-id", "--sakuracloud-private-only")
+		}
+		if ip := net.ParseIP(c.Gateway); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("%q must be set to valid IPv4 address when %q is specified", "--sakuracloud-gateway", "--sakuracloud-private-only")
+		}
+	}
+	return nil
+}
+
+// allowDiskSizes ディスクプランAPIが利用できない場合に用いるディスクサイズの一覧
+func allowDiskSizes(plan string) []int {
+	switch plan {
+	case "ssd":
+		return allowSSDSizes
+	case "hdd":
+		return allowHDDSizes
+	}
 	return nil
 }
 
@@ -146,16 +292,26 @@ var mcnFlags = []mcnflag.Flag{
 		Name:   "sakuracloud-access-token-secret",
 		Usage:  "sakuracloud access token secret",
 	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_PROFILE",
+		Name:   "sakuracloud-profile",
+		Usage:  "usacloud profile name to load access token/secret and zone from",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_API_ROOT_URL",
+		Name:   "sakuracloud-api-root-url",
+		Usage:  "Root URL of the sakuracloud API (for testing with a mock server)",
+	},
 	mcnflag.StringFlag{
 		EnvVar: "SAKURACLOUD_ZONE",
 		Name:   "sakuracloud-zone",
-		Usage:  "sakuracloud zone name[is1a/is1b/tk1a/tk1b]",
-		Value:  defaultRegion,
+		// プロファイルのゾーンを優先するため、デフォルト値(defaultRegion)はSetConfigFromFlagsで設定する
+		Usage: fmt.Sprintf("sakuracloud zone name[is1a/is1b/tk1a/tk1b] (default: profile's zone or %q)", defaultRegion),
 	},
 	mcnflag.StringFlag{
 		EnvVar: "SAKURACLOUD_OS_TYPE",
 		Name:   "sakuracloud-os-type",
-		Usage:  fmt.Sprintf("sakuracloud os(public-archive) type[%s]", strings.Join(allowOSTypes, "/")),
+		Usage:  fmt.Sprintf("sakuracloud os(public-archive) type[%s] or archive filter[name:<archive name>/tag:<tag>[,<tag>...]]", strings.Join(allowOSTypes, "/")),
 		Value:  defaultOSType,
 	},
 	mcnflag.IntFlag{
@@ -175,6 +331,48 @@ var mcnFlags = []mcnflag.Flag{
 		Name:   "sakuracloud-gpu",
 		Usage:  "sakuracloud number of GPUs",
 	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_COMMITMENT",
+		Name:   "sakuracloud-commitment",
+		Usage:  fmt.Sprintf("sakuracloud plan commitment[%s]", strings.Join(allowCommitments, "/")),
+		Value:  defaultCommitment,
+	},
+	mcnflag.IntFlag{
+		EnvVar: "SAKURACLOUD_PLAN_GENERATION",
+		Name:   "sakuracloud-plan-generation",
+		Usage:  "sakuracloud plan generation[0(auto)/100/200]",
+		Value:  defaultPlanGeneration,
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_SERVER_ID",
+		Name:   "sakuracloud-server-id",
+		Usage:  "sakuracloud existing server id to adopt instead of creating a new server",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_SOURCE_ARCHIVE_ID",
+		Name:   "sakuracloud-source-archive-id",
+		Usage:  "sakuracloud source archive id(use instead of the public archive of os-type)",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_SOURCE_DISK_ID",
+		Name:   "sakuracloud-source-disk-id",
+		Usage:  "sakuracloud source disk id(clone the disk instead of the public archive of os-type)",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_TAG",
+		Name:   "sakuracloud-tag",
+		Usage:  "sakuracloud tag of the server and disks",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_DESCRIPTION",
+		Name:   "sakuracloud-description",
+		Usage:  "sakuracloud description of the server and disks",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_ICON_ID",
+		Name:   "sakuracloud-icon-id",
+		Usage:  "sakuracloud icon id of the server and disks",
+	},
 	mcnflag.StringFlag{
 		EnvVar: "SAKURACLOUD_DISK_PLAN",
 		Name:   "sakuracloud-disk-plan",
@@ -193,6 +391,27 @@ var mcnFlags = []mcnflag.Flag{
 		Usage:  fmt.Sprintf("sakuracloud disk connection[%s]", strings.Join(allowDiskConnections, "/")),
 		Value:  defaultDiskConnection,
 	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_ADDITIONAL_DISK",
+		Name:   "sakuracloud-additional-disk",
+		Usage:  "sakuracloud additional blank disk[plan=ssd,size=100,connection=virtio]",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_STARTUP_SCRIPT",
+		Name:   "sakuracloud-startup-script",
+		Usage:  "sakuracloud startup script to run at first boot[file path/inline script/startup script ID]",
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_KEEP_STARTUP_SCRIPTS",
+		Name:   "sakuracloud-keep-startup-scripts",
+		Usage:  "Keep the startup scripts created by the driver after the server is created",
+	},
+	mcnflag.IntFlag{
+		EnvVar: "SAKURACLOUD_STARTUP_SCRIPT_TIMEOUT",
+		Name:   "sakuracloud-startup-script-timeout",
+		Usage:  "Seconds to wait for the server to shut down after running the startup scripts",
+		Value:  defaultStartupScriptTimeout,
+	},
 	mcnflag.StringFlag{
 		EnvVar: "SAKURACLOUD_INTERFACE_DRIVER",
 		Name:   "sakuracloud-interface-driver",
@@ -210,6 +429,71 @@ var mcnFlags = []mcnflag.Flag{
 		Usage:  "sakuracloud packet-filter for eth0(shared)[filter ID]",
 		Value:  defaultPacketFilter,
 	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_CREATE_PACKET_FILTER",
+		Name:   "sakuracloud-create-packet-filter",
+		Usage:  "sakuracloud create a packet filter for eth0 which allows only SSH and docker engine port(removed with the machine)",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_PACKET_FILTER_ALLOW_PORT",
+		Name:   "sakuracloud-packet-filter-allow-port",
+		Usage:  "sakuracloud additional TCP port to allow in the created packet filter[port or port range(e.g. 8000-8080)]",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_PACKET_FILTER_SOURCE",
+		Name:   "sakuracloud-packet-filter-source",
+		Usage:  "sakuracloud source network to allow in the created packet filter[IP address or CIDR](default: any)",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_SWITCH_ID",
+		Name:   "sakuracloud-switch-id",
+		Usage:  "sakuracloud switch ID to connect an additional NIC(eth1 or later) to",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_PRIVATE_IP",
+		Name:   "sakuracloud-private-ip",
+		Usage:  "sakuracloud IP address of the additional NIC connected to --sakuracloud-switch-id",
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_PRIVATE_NETMASK",
+		Name:   "sakuracloud-private-netmask",
+		Usage:  fmt.Sprintf("sakuracloud netmask of the additional NIC[mask length or dotted decimal](default: %d)", defaultPrivateNetmask),
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_PRIVATE_ONLY",
+		Name:   "sakuracloud-private-only",
+		Usage:  "sakuracloud connect eth0 to the first --sakuracloud-switch-id instead of the shared segment",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_GATEWAY",
+		Name:   "sakuracloud-gateway",
+		Usage:  "sakuracloud default route for eth0 in private-only mode",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_VPC_ROUTER_ID",
+		Name:   "sakuracloud-vpc-router-id",
+		Usage:  "sakuracloud VPC router ID which has port forwardings for SSH and docker engine in private-only mode",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_FORWARD_ENDPOINT",
+		Name:   "sakuracloud-forward-endpoint",
+		Usage:  "sakuracloud endpoint[host:port] whose port is forwarded to SSH of the machine in private-only mode, the engine port of the host must also be forwarded",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_BASTION_HOST",
+		Name:   "sakuracloud-bastion-host",
+		Usage:  "sakuracloud bastion host[host:port] to tunnel SSH and docker engine of the machine through in private-only mode",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_BASTION_USER",
+		Name:   "sakuracloud-bastion-user",
+		Usage:  "sakuracloud SSH user name of --sakuracloud-bastion-host",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_BASTION_KEY",
+		Name:   "sakuracloud-bastion-key",
+		Usage:  "sakuracloud SSH private key path of --sakuracloud-bastion-host",
+	},
 	mcnflag.BoolFlag{
 		EnvVar: "SAKURACLOUD_ENABLE_PASSWORD_AUTH",
 		Name:   "sakuracloud-enable-password-auth",
@@ -227,4 +511,57 @@ var mcnFlags = []mcnflag.Flag{
 		Usage:  "SSH Private Key Path",
 		Value:  "",
 	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_SSH_KEY_ID",
+		Name:   "sakuracloud-ssh-key-id",
+		Usage:  "ID of the registered SSH key to install its public key in addition to the key of the machine",
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_REGISTER_SSH_KEY",
+		Name:   "sakuracloud-register-ssh-key",
+		Usage:  "Register the public key of the machine as a SSH key of sakuracloud(deleted on removal)",
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_RESTART_WITH_RESET",
+		Name:   "sakuracloud-restart-with-reset",
+		Usage:  "Use the reset API instead of shutdown and boot when restarting the machine",
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_KEEP_ON_FAILURE",
+		Name:   "sakuracloud-keep-on-failure",
+		Usage:  "Keep the resources created before a failure of creating the machine for debugging",
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_DRY_RUN",
+		Name:   "sakuracloud-dry-run",
+		Usage:  "Validate the parameters and print the resources to be created with the estimated cost, without creating anything",
+	},
+	mcnflag.BoolFlag{
+		EnvVar: "SAKURACLOUD_ARCHIVE_ON_REMOVE",
+		Name:   "sakuracloud-archive-on-remove",
+		Usage:  "sakuracloud create an archive from the disk before removing the machine",
+	},
+	mcnflag.StringFlag{
+		EnvVar: "SAKURACLOUD_ARCHIVE_NAME",
+		Name:   "sakuracloud-archive-name",
+		Usage:  "sakuracloud name template of the archive[{{.MachineName}}/{{.ServerID}}/{{.DiskID}}/{{.Timestamp}}]",
+		Value:  defaultArchiveName,
+	},
+	mcnflag.StringSliceFlag{
+		EnvVar: "SAKURACLOUD_ARCHIVE_TAG",
+		Name:   "sakuracloud-archive-tag",
+		Usage:  "sakuracloud tag of the archive",
+	},
+	mcnflag.IntFlag{
+		EnvVar: "SAKURACLOUD_WAIT_TIMEOUT",
+		Name:   "sakuracloud-wait-timeout",
+		Usage:  "Timeout in seconds for waiting the server to be up or down",
+		Value:  defaultWaitTimeout,
+	},
+	mcnflag.IntFlag{
+		EnvVar: "SAKURACLOUD_WAIT_INTERVAL",
+		Name:   "sakuracloud-wait-interval",
+		Usage:  "Interval in seconds for polling the server state",
+		Value:  defaultWaitInterval,
+	},
 }
@@ -0,0 +1,205 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+
+	"github.com/sacloud/docker-machine-sakuracloud/sakuracloud"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// startupScriptConfig --sakuracloud-startup-scriptで指定されたスタートアップスクリプト
+type startupScriptConfig struct {
+	// Source 指定された値(ファイルパス/ノートID)、インラインの場合は"inline#<n>"
+	Source string
+	// NoteID 既存のスタートアップスクリプトを指定した場合のID
+	NoteID types.ID
+	// Content スクリプトの内容、ファイルもしくはインラインで指定された場合は変数展開済み
+	//
+	// 既存のスタートアップスクリプトはサーバ作成時に指定するとドライバのスクリプトより後に実行されるため、
+	// 内容を読み込んで他のスクリプトと同様に実行順を制御する
+	Content string
+}
+
+// startupScriptLogPath さくらのクラウドのスタートアップスクリプトの実行ログ
+const startupScriptLogPath = "/root/.sacloud-api/notes/*.log"
+
+// startupScriptParams スタートアップスクリプト内のテンプレートで利用できる値
+type startupScriptParams struct {
+	MachineName      string
+	EnginePort       int
+	PrivateIPAddress string // 1つ目のプライベートNICのIPアドレス
+}
+
+// parseStartupScripts --sakuracloud-startup-scriptの値を読み込み、テンプレートを展開する
+//
+// 値は以下のように解釈する
+//   - 改行を含むか"#!"で始まる場合: スクリプトの内容(インライン)
+//   - 数字のみの場合: 既存のスタートアップスクリプトのID
+//   - それ以外: ファイルパス
+func parseStartupScripts(values []string, params *startupScriptParams) ([]*startupScriptConfig, error) {
+	var scripts []*startupScriptConfig
+	for i, v := range values {
+		var script *startupScriptConfig
+		switch {
+		case strings.HasPrefix(v, "#!") || strings.Contains(v, "\n"):
+			script = &startupScriptConfig{Source: fmt.Sprintf("inline#%d", i+1), Content: v}
+		case isNumeric(v):
+			id := types.StringID(v)
+			if id.IsEmpty() {
+				return nil, fmt.Errorf("%q is invalid: %s", "--sakuracloud-startup-script", v)
+			}
+			script = &startupScriptConfig{Source: v, NoteID: id}
+		default:
+			data, err := os.ReadFile(v)
+			if err != nil {
+				return nil, fmt.Errorf("%q is invalid: %s", "--sakuracloud-startup-script", err)
+			}
+			script = &startupScriptConfig{Source: v, Content: string(data)}
+		}
+
+		if script.Content != "" {
+			content, err := renderStartupScript(script.Source, script.Content, params)
+			if err != nil {
+				return nil, err
+			}
+			script.Content = content
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+func renderStartupScript(source, content string, params *startupScriptParams) (string, error) {
+	t, err := template.New(source).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("%q is invalid: %s", "--sakuracloud-startup-script", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, params); err != nil {
+		return "", fmt.Errorf("%q is invalid: %s", "--sakuracloud-startup-script", err)
+	}
+	return buf.String(), nil
+}
+
+func isNumeric(v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, r := range v {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// startupNote サーバ作成時に登録するスタートアップスクリプト
+type startupNote struct {
+	Name    string // エラーメッセージなどで表示する名前
+	Content string
+}
+
+// startupNotes サーバ作成時に登録するスタートアップスクリプトを実行順に返す
+func (d *Driver) startupNotes() []*startupNote {
+	var notes []*startupNote
+	if d.serverConfig.IsUbuntu() {
+		// allow sudo by ubuntu user first, so that the logs of the following scripts can be read even if they fail
+		notes = append(notes, &startupNote{Name: "sudo setting for ubuntu user", Content: sakuraAllowSudoScriptBody})
+	}
+	if script := d.serverConfig.privateNICScript(); script != "" {
+		// configure additional NICs before the shutdown is scheduled by the following scripts
+		notes = append(notes, &startupNote{Name: "private NIC setting", Content: script})
+	}
+	// user scripts also run before the shutdown is scheduled
+	for _, script := range d.serverConfig.StartupScripts {
+		if script.Content != "" {
+			notes = append(notes, &startupNote{Name: script.Source, Content: script.Content})
+		}
+	}
+	if d.serverConfig.IsUbuntu() {
+		notes = append(notes, &startupNote{Name: "shutdown after startup scripts", Content: sakuraShutdownScriptBody})
+	} else if d.serverConfig.IsRHEL() {
+		notes = append(notes, &startupNote{Name: "net-tools installation", Content: fmt.Sprintf(sakuraInstallNetToolsScriptBody, d.EnginePort)})
+	}
+	return notes
+}
+
+// startupScriptTimeout スタートアップスクリプト実行後のシャットダウンを待つ時間
+func (d *Driver) startupScriptTimeout() time.Duration {
+	if d.serverConfig.StartupScriptTimeout <= 0 {
+		return time.Duration(defaultStartupScriptTimeout) * time.Second
+	}
+	return time.Duration(d.serverConfig.StartupScriptTimeout) * time.Second
+}
+
+// waitForStartupScripts スタートアップスクリプトの実行が完了し、サーバがシャットダウンされるまで待つ
+//
+// スクリプトが失敗した場合はシャットダウンされないため、
+// 指定時間を過ぎても起動したままの場合は実行したスクリプトとログを含むエラーを返す
+func (d *Driver) waitForStartupScripts(ctx context.Context) error {
+	timeout := d.startupScriptTimeout()
+	log.Infof("Waiting for startup scripts to finish (timeout: %s)", timeout)
+
+	err := d.waitForServerByStateWithin(ctx, state.Stopped, timeout)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*serverNotFoundError); ok {
+		return err
+	}
+
+	var names []string
+	for _, note := range d.startupNotes() {
+		names = append(names, note.Name)
+	}
+	msg := fmt.Sprintf("server[id:%s] did not shut down within %s after running startup scripts [%s]: %s",
+		d.ID, timeout, strings.Join(names, ", "), err)
+	if output := d.startupScriptLogs(); output != "" {
+		msg += fmt.Sprintf("\nstartup script logs:\n%s", output)
+	} else {
+		msg += fmt.Sprintf("\nsee %s on the server for details(use --sakuracloud-keep-on-failure to keep the server)", startupScriptLogPath)
+	}
+	return errors.New(msg)
+}
+
+// startupScriptLogs サーバからスタートアップスクリプトのログを取得する、取得できない場合は空文字を返す
+//
+// ログはroot以外では参照できないため、ubuntuユーザーの場合は最初に実行するスクリプトで設定したsudoを利用する
+func (d *Driver) startupScriptLogs() string {
+	cmd := fmt.Sprintf("sudo -n tail -n 20 %s 2>/dev/null || tail -n 20 %s", startupScriptLogPath, startupScriptLogPath)
+	output, err := d.runSSHCommand(d, cmd)
+	if err != nil {
+		log.Debugf("Failed to get startup script logs: %s", err)
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// resolveStartupScriptNotes IDで指定された既存のスタートアップスクリプトの内容を読み込む
+func resolveStartupScriptNotes(ctx context.Context, client *sakuracloud.APIClient, config *sakuraServerConfig) error {
+	for _, script := range config.StartupScripts {
+		if script.NoteID.IsEmpty() {
+			continue
+		}
+		note, err := client.ReadNote(ctx, script.NoteID)
+		if err != nil {
+			if sacloud.IsNotFoundError(err) {
+				return fmt.Errorf("startup script[id:%s] is not exists", script.NoteID)
+			}
+			return err
+		}
+		script.Content = note.Content
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// serverInstanceStatusMigrating 起動処理中(ホストへの配置中)のインスタンスステータス
+//
+// libsacloudには定義されていないが、起動直後にAPIから返される
+const serverInstanceStatusMigrating = types.EServerInstanceStatus("migrating")
+
+// serverNotFoundError docker-machineの外でサーバが削除された場合のエラー
+type serverNotFoundError struct {
+	ID string
+}
+
+func (e *serverNotFoundError) Error() string {
+	return fmt.Sprintf("server[id:%s] is not found, it may have been deleted outside of docker-machine", e.ID)
+}
+
+// serverState サーバのインスタンスステータスとAvailabilityをdocker-machineの状態に変換する
+func serverState(id string, status types.EServerInstanceStatus, availability types.EAvailability) (state.State, error) {
+	switch availability {
+	case types.Availabilities.Failed:
+		return state.Error, fmt.Errorf("server[id:%s] is in failed state", id)
+	case types.Availabilities.Discontinued:
+		return state.Error, fmt.Errorf("server[id:%s] is discontinued", id)
+	}
+
+	switch status {
+	case types.ServerInstanceStatuses.Up:
+		return state.Running, nil
+	case types.ServerInstanceStatuses.Cleaning:
+		return state.Stopping, nil
+	case types.ServerInstanceStatuses.Down:
+		return state.Stopped, nil
+	case serverInstanceStatusMigrating:
+		return state.Starting, nil
+	case types.ServerInstanceStatuses.Unknown:
+		// 作成直後などでインスタンスの情報がまだない場合
+		switch availability {
+		case types.Availabilities.Migrating, types.Availabilities.Uploading, types.Availabilities.Transferring:
+			return state.Starting, nil
+		case types.Availabilities.Available:
+			return state.Stopped, nil
+		}
+	}
+	return state.Error, fmt.Errorf("server[id:%s] has unknown status: instance status %q, availability %q", id, status, availability)
+}
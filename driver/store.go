@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// saveStoredConfig config store(config.json)のDriverの値を現在の値で更新する
+//
+// docker-machineはCreateが失敗した場合に設定を保存しないため、残したリソースのIDはドライバから保存する
+func (d *Driver) saveStoredConfig() error {
+	configPath := d.ResolveStorePath("config.json")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("unable to read config of machine %q: %v", d.GetMachineName(), err)
+	}
+	return saveDriverConfig(configPath, config, d)
+}
+
+// saveDriverConfig config.jsonのDriver以外の項目はそのままにDriverの値を書き込む
+func saveDriverConfig(configPath string, config map[string]json.RawMessage, d *Driver) error {
+	driverData, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	config["Driver"] = driverData
+
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// 書き込み途中で失敗してもconfig.jsonが壊れないよう一時ファイルから置き換える
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), "config.json.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() // nolint
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), configPath)
+}
@@ -0,0 +1,33 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+const machineTag = "docker-machine" // docker-machineで作成したリソースに付与するタグ
+
+// machineTags マシンを識別するためのタグを返す
+//
+// コントロールパネルなどから作成元のマシン名、ストア(--storage-path)を判別できるようにする。
+func machineTags(machineName, storePath string) types.Tags {
+	storeHash := fmt.Sprintf("%x", sha256.Sum256([]byte(storePath)))[:8]
+	return types.Tags{
+		machineTag,
+		fmt.Sprintf("%s-name=%s", machineTag, machineName),
+		fmt.Sprintf("%s-store=%s", machineTag, storeHash),
+	}
+}
+
+// resourceTags --sakuracloud-tagの値とマシンを識別するためのタグを合わせて返す
+func (c *sakuraServerConfig) resourceTags() types.Tags {
+	tags := append(types.Tags{}, c.Tags...)
+	for _, tag := range c.MachineTags {
+		if !c.isStrInValue(tag, tags...) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
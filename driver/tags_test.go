@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMachineTags(t *testing.T) {
+	storePath := "/home/user/.docker/machine"
+	storeHash := fmt.Sprintf("%x", sha256.Sum256([]byte(storePath)))[:8]
+
+	tags := machineTags("machine1", storePath)
+	assert.Equal(t, types.Tags{
+		"docker-machine",
+		"docker-machine-name=machine1",
+		"docker-machine-store=" + storeHash,
+	}, tags)
+
+	// 同じストアであれば同じタグ、異なるストアであれば異なるタグとなる
+	assert.Equal(t, tags, machineTags("machine1", storePath))
+	assert.NotEqual(t, tags[2], machineTags("machine1", "/tmp/machine")[2])
+}
+
+func TestSakuraServerConfig_ResourceTags(t *testing.T) {
+	machine := machineTags("machine1", "/home/user/.docker/machine")
+
+	cases := []struct {
+		name   string
+		tags   types.Tags
+		expect types.Tags
+	}{
+		{
+			name:   "without user tags",
+			expect: machine,
+		},
+		{
+			name:   "with user tags",
+			tags:   types.Tags{"tag1", "tag2"},
+			expect: append(types.Tags{"tag1", "tag2"}, machine...),
+		},
+		{
+			name:   "duplicated with machine tags",
+			tags:   types.Tags{"docker-machine", "tag1"},
+			expect: append(types.Tags{"docker-machine", "tag1"}, machine[1:]...),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &sakuraServerConfig{Tags: tc.tags, MachineTags: machine}
+			assert.Equal(t, tc.expect, config.resourceTags())
+			// --sakuracloud-tagの値は変更しない
+			assert.Equal(t, tc.tags, config.Tags)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// maxWaitBackoff APIエラー時の待機間隔の上限
+const maxWaitBackoff = time.Minute
+
+func (d *Driver) waitTimeout() time.Duration {
+	if d.WaitTimeout <= 0 {
+		return time.Duration(defaultWaitTimeout) * time.Second
+	}
+	return time.Duration(d.WaitTimeout) * time.Second
+}
+
+func (d *Driver) waitInterval() time.Duration {
+	if d.WaitInterval <= 0 {
+		return time.Duration(defaultWaitInterval) * time.Second
+	}
+	return time.Duration(d.WaitInterval) * time.Second
+}
+
+// waitForServerByState サーバが指定の状態になるまで待つ
+//
+// APIエラー時は待機間隔を指数的に延ばしながらリトライし、タイムアウトした場合はエラーを返す。
+func (d *Driver) waitForServerByState(ctx context.Context, waitForState state.State) error {
+	return d.waitForServerByStateWithin(ctx, waitForState, d.waitTimeout())
+}
+
+// waitForServerByStateWithin サーバが指定の状態になるまで、指定時間を上限に待つ
+func (d *Driver) waitForServerByStateWithin(ctx context.Context, waitForState state.State, timeout time.Duration) error {
+	log.Infof("Waiting for server to become %v", waitForState)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	interval := d.waitInterval()
+	backoff := interval
+	var lastErr error
+	for {
+		wait := interval
+		s, err := d.getState(ctx)
+		if _, ok := err.(*serverNotFoundError); ok {
+			return err
+		}
+		if err != nil {
+			log.Debugf("Failed to get Server State - %+v", err)
+			lastErr = err
+			wait = backoff
+			backoff *= 2
+			if backoff > maxWaitBackoff {
+				backoff = maxWaitBackoff
+			}
+		} else {
+			if s == waitForState {
+				return nil
+			}
+			log.Debugf("Still waiting - state is %s...", s)
+			lastErr = nil
+			backoff = interval
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("timed out waiting for server to become %v: %v", waitForState, lastErr)
+			}
+			return fmt.Errorf("timed out waiting for server to become %v: current state is %v", waitForState, s)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// waitForDeletion リソースが削除されるまで待つ
+func (d *Driver) waitForDeletion(ctx context.Context, name string, exists func(ctx context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, d.waitTimeout())
+	defer cancel()
+
+	var lastErr error
+	for {
+		found, err := exists(ctx)
+		if err == nil && !found {
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("timed out waiting for %s to be removed: %v", name, lastErr)
+			}
+			return fmt.Errorf("timed out waiting for %s to be removed", name)
+		case <-time.After(d.waitInterval()):
+		}
+	}
+}
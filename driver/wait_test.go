@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/state"
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/fake"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServerOp 指定回数だけサーバの参照に失敗するServerAPI、failuresが負の場合は常に失敗する
+type flakyServerOp struct {
+	sacloud.ServerAPI
+	failures int
+	reads    int
+}
+
+func (o *flakyServerOp) Read(ctx context.Context, zone string, id types.ID) (*sacloud.Server, error) {
+	o.reads++
+	if o.failures != 0 {
+		o.failures--
+		return nil, errors.New("service unavailable")
+	}
+	return o.ServerAPI.Read(ctx, zone, id)
+}
+
+func TestDriver_WaitForServerByStateTimeout(t *testing.T) {
+	d := newFakeDriver(t, nil)
+	createFakeServer(t, d, types.ServerInstanceStatuses.Up)
+
+	start := time.Now()
+	err := d.waitForServerByStateWithin(context.Background(), state.Stopped, 1500*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out waiting for server to become Stopped: current state is Running")
+	assert.True(t, time.Since(start) >= 1500*time.Millisecond, "should wait until the timeout")
+}
+
+func TestDriver_WaitForServerByStateRetry(t *testing.T) {
+	d := newFakeDriver(t, nil)
+	createFakeServer(t, d, types.ServerInstanceStatuses.Down)
+
+	t.Run("recover", func(t *testing.T) {
+		op := &flakyServerOp{ServerAPI: fake.NewServerOp(), failures: 2}
+		replaceFakeOp(t, fake.ResourceServer, op, fake.NewServerOp())
+
+		require.NoError(t, d.waitForServerByStateWithin(context.Background(), state.Stopped, 10*time.Second))
+		assert.Equal(t, 3, op.reads)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		op := &flakyServerOp{ServerAPI: fake.NewServerOp(), failures: -1}
+		replaceFakeOp(t, fake.ResourceServer, op, fake.NewServerOp())
+
+		err := d.waitForServerByStateWithin(context.Background(), state.Stopped, 3500*time.Millisecond)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out waiting for server to become Stopped: service unavailable")
+		// 1秒間隔のままであれば4回、待機間隔を延ばした場合は0/1/3秒後の3回となる
+		assert.Equal(t, 3, op.reads)
+	})
+}
+
+func TestDriver_WaitForServerByStateNotFound(t *testing.T) {
+	d := newFakeDriver(t, nil)
+	d.ID = "999999999999"
+
+	// サーバが存在しない場合はタイムアウトを待たずに返る
+	start := time.Now()
+	err := d.waitForServerByStateWithin(context.Background(), state.Stopped, 10*time.Second)
+	require.Error(t, err)
+	assert.IsType(t, &serverNotFoundError{}, err)
+	assert.True(t, time.Since(start) < time.Second, "should not wait until the timeout")
+}
@@ -9,4 +9,5 @@ require (
 	github.com/sacloud/libsacloud/v2 v2.26.1-0.20211008014615-db5d9d9b3689
 	github.com/stretchr/testify v1.6.1
 	github.com/urfave/cli v1.22.1
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 )
@@ -0,0 +1,67 @@
+package sakuracloud
+
+import (
+	"context"
+	"time"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/search"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// IsExistsArchive returns true if Archive is exists
+func (c *APIClient) IsExistsArchive(ctx context.Context, id types.ID) (bool, error) {
+	archive, err := sacloud.NewArchiveOp(c.caller).Read(ctx, c.Zone, id)
+	if err != nil {
+		if sacloud.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return archive != nil, nil
+}
+
+// FindArchives returns archives matched with filter
+func (c *APIClient) FindArchives(ctx context.Context, filter search.Filter) ([]*sacloud.Archive, error) {
+	searched, err := sacloud.NewArchiveOp(c.caller).Find(ctx, c.Zone, &sacloud.FindCondition{
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return searched.Archives, nil
+}
+
+// CreateArchiveFromDisk creates an archive from the disk and waits until the copy is completed
+func (c *APIClient) CreateArchiveFromDisk(ctx context.Context, diskID types.ID, name, description string, tags types.Tags, timeout time.Duration) (types.ID, error) {
+	op := sacloud.NewArchiveOp(c.caller)
+	archive, err := op.Create(ctx, c.Zone, &sacloud.ArchiveCreateRequest{
+		SourceDiskID: diskID,
+		Name:         name,
+		Description:  description,
+		Tags:         tags,
+	})
+	if err != nil {
+		return types.ID(0), err
+	}
+
+	waiter := &sacloud.StatePollingWaiter{
+		ReadFunc: func() (interface{}, error) {
+			return op.Read(ctx, c.Zone, archive.ID)
+		},
+		TargetAvailability: []types.EAvailability{
+			types.Availabilities.Available,
+		},
+		PendingAvailability: []types.EAvailability{
+			types.Availabilities.Unknown,
+			types.Availabilities.Migrating,
+			types.Availabilities.Uploading,
+			types.Availabilities.Transferring,
+		},
+		Timeout: timeout,
+	}
+	if _, err := waiter.WaitForState(ctx); err != nil {
+		return archive.ID, err
+	}
+	return archive.ID, nil
+}
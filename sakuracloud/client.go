@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/sacloud/docker-machine-sakuracloud/version"
@@ -11,36 +12,46 @@ import (
 	"github.com/sacloud/libsacloud/v2/helper/builder/server"
 	"github.com/sacloud/libsacloud/v2/helper/query"
 	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/profile"
 	"github.com/sacloud/libsacloud/v2/sacloud/types"
 )
 
 // APIClient client for SakuraCloud API
 type APIClient struct {
+	// AccessToken/AccessTokenSecret 値もしくはシークレットの参照形式(env:/file:/keyring:/cmd:)
 	AccessToken       string
 	AccessTokenSecret string
 	Zone              string
 	Region            string // 後方互換
-	Password          string // config storeへ残しておくため
+	Password          string // 後方互換: 以前のバージョンでは生成したパスワードをconfig storeへ残していた
+
+	// Profile usacloudのプロファイル名
+	//
+	// プロファイルから読み込んだアクセストークン/シークレットはconfig storeへ残さない
+	Profile string
+
+	// APIRootURL APIのルートURL、未指定の場合はプロファイルの値もしくはlibsacloudのデフォルト値を利用する
+	APIRootURL string
 
 	/*
 		Note: 以下エクスポートしていない項目は適切にconfig storeからのUnmarshalに対応すること
 			  -> 例: APIClient.Init()
 	*/
 
-	caller   sacloud.APICaller
-	initOnce sync.Once
+	caller            sacloud.APICaller
+	initOnce          sync.Once
+	initErr           error
+	profileConfig     *profile.ConfigValue
+	accessTokenValue  string
+	accessSecretValue string
 }
 
 // NewAPIClient returns new APIClient
-func NewAPIClient(token, secret, zone, password string) *APIClient {
-	caller := initCaller(token, secret)
-
+func NewAPIClient(token, secret, zone string) *APIClient {
 	return &APIClient{
 		AccessToken:       token,
 		AccessTokenSecret: secret,
 		Zone:              zone,
-		Password:          password,
-		caller:            caller,
 	}
 }
 
@@ -65,28 +76,85 @@ func (c *APIClient) DiskBuilderClient() *disk.APIClient {
 	return disk.NewBuildersAPIClient(c.caller)
 }
 
+// LoadProfile loads usacloud profile
+//
+// AccessToken/AccessTokenSecret/Zone take precedence over the values of the profile
+func (c *APIClient) LoadProfile() error {
+	if c.Profile == "" || c.profileConfig != nil {
+		return nil
+	}
+	config := &profile.ConfigValue{}
+	if err := profile.Load(c.Profile, config); err != nil {
+		return fmt.Errorf("loading profile %q is failed: %s", c.Profile, err)
+	}
+	c.profileConfig = config
+	if c.Zone == "" {
+		c.Zone = config.Zone
+	}
+	return nil
+}
+
+// resolveCredentials プロファイルやシークレットの参照形式からアクセストークン/シークレットを読み込む
+func (c *APIClient) resolveCredentials() error {
+	if err := c.LoadProfile(); err != nil {
+		return err
+	}
+	token, err := ResolveSecret(c.AccessToken)
+	if err != nil {
+		return fmt.Errorf("resolving access token is failed: %s", err)
+	}
+	secret, err := ResolveSecret(c.AccessTokenSecret)
+	if err != nil {
+		return fmt.Errorf("resolving access token secret is failed: %s", err)
+	}
+	if token == "" && c.profileConfig != nil {
+		token = c.profileConfig.AccessToken
+	}
+	if secret == "" && c.profileConfig != nil {
+		secret = c.profileConfig.AccessTokenSecret
+	}
+	c.accessTokenValue, c.accessSecretValue = token, secret
+	return nil
+}
+
+func (c *APIClient) apiRootURL() string {
+	if c.APIRootURL == "" && c.profileConfig != nil {
+		return c.profileConfig.APIRootURL
+	}
+	return c.APIRootURL
+}
+
 // Init initialize APIClient
-func (c *APIClient) Init() {
+//
+// プロファイルやシークレットの読み込みに失敗した場合はエラーを返す。
+// この場合もAPI呼び出しは行えるが、認証エラーとなる
+func (c *APIClient) Init() error {
 	c.initOnce.Do(func() {
 		if c.Zone == "" && c.Region != "" {
 			c.Zone = c.Region
 		}
+		c.initErr = c.resolveCredentials()
+		if rootURL := c.apiRootURL(); rootURL != "" {
+			// libsacloudはルートURLをパッケージ変数で保持しているため、プロセス全体に反映される
+			sacloud.SakuraCloudAPIRoot = strings.TrimRight(rootURL, "/")
+		}
+		c.caller = initCaller(c.accessTokenValue, c.accessSecretValue)
 	})
-	if c.caller == nil {
-		c.caller = initCaller(c.AccessToken, c.AccessTokenSecret)
-	}
+	return c.initErr
 }
 
 // ValidateClientConfig validates client config
 func (c *APIClient) ValidateClientConfig() error {
-	c.Init()
+	if err := c.Init(); err != nil {
+		return err
+	}
 
-	if c.AccessToken == "" {
-		return fmt.Errorf("Missing required setting - --sakuracloud-access-token")
+	if c.accessTokenValue == "" {
+		return fmt.Errorf("Missing required setting - --sakuracloud-access-token or --sakuracloud-profile")
 	}
 
-	if c.AccessTokenSecret == "" {
-		return fmt.Errorf("Missing required setting - --sakuracloud-access-token-secret")
+	if c.accessSecretValue == "" {
+		return fmt.Errorf("Missing required setting - --sakuracloud-access-token-secret or --sakuracloud-profile")
 	}
 	if c.Zone == "" {
 		return fmt.Errorf("Missing required setting - --sakuracloud-zone")
@@ -95,11 +163,13 @@ func (c *APIClient) ValidateClientConfig() error {
 }
 
 // IsValidPlan validates plan
-func (c *APIClient) IsValidPlan(core, memory, gpu int) (bool, error) {
-	plan, err := query.FindServerPlan(context.Background(), sacloud.NewServerPlanOp(c.caller), c.Zone, &query.FindServerPlanRequest{
-		CPU:      core,
-		MemoryGB: memory,
-		GPU:      gpu,
+func (c *APIClient) IsValidPlan(ctx context.Context, core, memory, gpu int, commitment types.ECommitment, generation types.EPlanGeneration) (bool, error) {
+	plan, err := query.FindServerPlan(ctx, sacloud.NewServerPlanOp(c.caller), c.Zone, &query.FindServerPlanRequest{
+		CPU:        core,
+		MemoryGB:   memory,
+		GPU:        gpu,
+		Commitment: commitment,
+		Generation: generation,
 	})
 	if err != nil {
 		return false, err
@@ -109,8 +179,8 @@ func (c *APIClient) IsValidPlan(core, memory, gpu int) (bool, error) {
 }
 
 // IsExistsPacketFilter returns true is PakcetFilter is exists
-func (c *APIClient) IsExistsPacketFilter(id types.ID) (bool, error) {
-	pf, err := sacloud.NewPacketFilterOp(c.caller).Read(context.Background(), c.Zone, id)
+func (c *APIClient) IsExistsPacketFilter(ctx context.Context, id types.ID) (bool, error) {
+	pf, err := sacloud.NewPacketFilterOp(c.caller).Read(ctx, c.Zone, id)
 	if err != nil {
 		if sacloud.IsNotFoundError(err) {
 			return false, nil
@@ -119,3 +189,15 @@ func (c *APIClient) IsExistsPacketFilter(id types.ID) (bool, error) {
 	}
 	return pf != nil, nil
 }
+
+// IsExistsSwitch returns true is Switch is exists
+func (c *APIClient) IsExistsSwitch(ctx context.Context, id types.ID) (bool, error) {
+	sw, err := sacloud.NewSwitchOp(c.caller).Read(ctx, c.Zone, id)
+	if err != nil {
+		if sacloud.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return sw != nil, nil
+}
@@ -0,0 +1,144 @@
+package sakuracloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAPIServer テスト用のAPIサーバを起動する
+//
+// APIRootURLを指定したクライアントはlibsacloudのパッケージ変数を書き換えるため、テスト後に元の値に戻す
+func newTestAPIServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	root := sacloud.SakuraCloudAPIRoot
+	t.Cleanup(func() { sacloud.SakuraCloudAPIRoot = root })
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAPIClient_APIRootURL(t *testing.T) {
+	var requested string
+	server := newTestAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		token, secret, _ := r.BasicAuth()
+		assert.Equal(t, "token", token)
+		assert.Equal(t, "secret", secret)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"is_fatal":true,"status":"404 Not Found","error_code":"not_found","error_msg":"not found"}`)) // nolint
+	})
+
+	client := NewAPIClient("token", "secret", "is1b")
+	client.APIRootURL = server.URL + "/"
+	require.NoError(t, client.ValidateClientConfig())
+
+	exists, err := client.IsExistsServer(context.Background(), 123456789012)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, "/is1b/api/cloud/1.1/server/123456789012", requested)
+}
+
+func TestAPIClient_APIError(t *testing.T) {
+	server := newTestAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"is_fatal":true,"status":"401 Unauthorized","error_code":"unauthorized","error_msg":"unauthorized"}`)) // nolint
+	})
+
+	client := NewAPIClient("token", "secret", "is1b")
+	client.APIRootURL = server.URL
+	require.NoError(t, client.ValidateClientConfig())
+
+	_, err := client.IsExistsServer(context.Background(), 123456789012)
+	assert.Error(t, err)
+}
+
+func TestAPIClient_SecretReference(t *testing.T) {
+	t.Setenv("TEST_SAKURACLOUD_TOKEN", "token-from-env")
+
+	client := NewAPIClient("env:TEST_SAKURACLOUD_TOKEN", "cmd:echo secret-from-command", "is1b")
+	require.NoError(t, client.ValidateClientConfig())
+	assert.Equal(t, "token-from-env", client.accessTokenValue)
+	assert.Equal(t, "secret-from-command", client.accessSecretValue)
+	// config storeには参照のみが残る
+	assert.Equal(t, "env:TEST_SAKURACLOUD_TOKEN", client.AccessToken)
+
+	client = NewAPIClient("env:TEST_SAKURACLOUD_NOT_EXISTS", "secret", "is1b")
+	assert.Error(t, client.ValidateClientConfig())
+}
+
+// setTestProfile t.TempDir()をプロファイルの格納先とし、指定のプロファイルを保存する
+func setTestProfile(t *testing.T, name string, config *profile.ConfigValue) {
+	dir := t.TempDir()
+	t.Setenv(profile.DirectoryNameEnv, dir)
+	t.Setenv(profile.DirectoryNameEnvOld, dir)
+	require.NoError(t, profile.Save(name, config))
+}
+
+func TestAPIClient_Profile(t *testing.T) {
+	setTestProfile(t, "test", &profile.ConfigValue{
+		AccessToken:       "profile-token",
+		AccessTokenSecret: "profile-secret",
+		Zone:              "tk1a",
+	})
+
+	t.Run("flag over profile", func(t *testing.T) {
+		client := NewAPIClient("flag-token", "flag-secret", "is1a")
+		client.Profile = "test"
+		require.NoError(t, client.ValidateClientConfig())
+		assert.Equal(t, "flag-token", client.accessTokenValue)
+		assert.Equal(t, "flag-secret", client.accessSecretValue)
+		assert.Equal(t, "is1a", client.Zone)
+	})
+
+	t.Run("profile over empty", func(t *testing.T) {
+		client := NewAPIClient("", "", "")
+		client.Profile = "test"
+		require.NoError(t, client.ValidateClientConfig())
+		assert.Equal(t, "profile-token", client.accessTokenValue)
+		assert.Equal(t, "profile-secret", client.accessSecretValue)
+		assert.Equal(t, "tk1a", client.Zone)
+		// config storeにはプロファイルの値を保存しない
+		assert.Empty(t, client.AccessToken)
+		assert.Empty(t, client.AccessTokenSecret)
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		client := NewAPIClient("", "", "")
+		client.Profile = "not-exists"
+		assert.Error(t, client.ValidateClientConfig())
+	})
+}
+
+func TestAPIClient_ProfileAPIRootURL(t *testing.T) {
+	var requested string
+	server := newTestAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"is_fatal":true,"status":"404 Not Found","error_code":"not_found","error_msg":"not found"}`)) // nolint
+	})
+	setTestProfile(t, "test", &profile.ConfigValue{
+		AccessToken:       "profile-token",
+		AccessTokenSecret: "profile-secret",
+		Zone:              "is1a",
+		APIRootURL:        server.URL,
+	})
+
+	client := NewAPIClient("", "", "")
+	client.Profile = "test"
+	require.NoError(t, client.ValidateClientConfig())
+
+	_, err := client.IsExistsServer(context.Background(), 123456789012)
+	require.NoError(t, err)
+	assert.Equal(t, "/is1a/api/cloud/1.1/server/123456789012", requested)
+}
@@ -0,0 +1,107 @@
+package sakuracloud
+
+import (
+	"context"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// DeleteDisk deletes disk
+func (c *APIClient) DeleteDisk(ctx context.Context, id types.ID) error {
+	return sacloud.NewDiskOp(c.caller).Delete(ctx, c.Zone, id)
+}
+
+// IsExistsDisk returns true if Disk is exists
+func (c *APIClient) IsExistsDisk(ctx context.Context, id types.ID) (bool, error) {
+	disk, err := sacloud.NewDiskOp(c.caller).Read(ctx, c.Zone, id)
+	if err != nil {
+		if sacloud.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return disk != nil, nil
+}
+
+// AvailableDiskSizes returns disk sizes(GB) available in the zone for the disk plan
+func (c *APIClient) AvailableDiskSizes(ctx context.Context, planID types.ID) ([]int, error) {
+	plan, err := sacloud.NewDiskPlanOp(c.caller).Read(ctx, c.Zone, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sizes []int
+	for _, size := range plan.Size {
+		if size.Availability.IsAvailable() {
+			sizes = append(sizes, size.SizeMB/1024)
+		}
+	}
+	return sizes, nil
+}
+
+// EditDiskSSHKeys installs SSH public keys into the disk with the disk edit API and waits until the disk is ready
+func (c *APIClient) EditDiskSSHKeys(ctx context.Context, id types.ID, publicKeys ...string) error {
+	op := sacloud.NewDiskOp(c.caller)
+
+	var keys []*sacloud.DiskEditSSHKey
+	for _, key := range publicKeys {
+		keys = append(keys, &sacloud.DiskEditSSHKey{PublicKey: key})
+	}
+	if err := op.Config(ctx, c.Zone, id, &sacloud.DiskEditRequest{SSHKeys: keys}); err != nil {
+		return err
+	}
+	return c.waitForDiskReady(ctx, id)
+}
+
+// ReadDisk reads disk
+func (c *APIClient) ReadDisk(ctx context.Context, id types.ID) (*sacloud.Disk, error) {
+	return sacloud.NewDiskOp(c.caller).Read(ctx, c.Zone, id)
+}
+
+// CloneDisk creates a copy of the disk with the size and waits until the disk is ready
+func (c *APIClient) CloneDisk(ctx context.Context, source *sacloud.Disk, sizeGB int) (*sacloud.Disk, error) {
+	op := sacloud.NewDiskOp(c.caller)
+	disk, err := op.Create(ctx, c.Zone, &sacloud.DiskCreateRequest{
+		DiskPlanID:   source.DiskPlanID,
+		Connection:   source.Connection,
+		SourceDiskID: source.ID,
+		SizeMB:       sizeGB * 1024,
+		Name:         source.Name,
+		Description:  source.Description,
+		Tags:         source.Tags,
+		IconID:       source.IconID,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitForDiskReady(ctx, disk.ID); err != nil {
+		return disk, err
+	}
+	return disk, nil
+}
+
+// ResizePartition expands the partition of the disk to the disk size and waits until the disk is ready
+func (c *APIClient) ResizePartition(ctx context.Context, id types.ID) error {
+	if err := sacloud.NewDiskOp(c.caller).ResizePartition(ctx, c.Zone, id, &sacloud.DiskResizePartitionRequest{}); err != nil {
+		return err
+	}
+	return c.waitForDiskReady(ctx, id)
+}
+
+// ConnectDisk connects the disk to the server
+func (c *APIClient) ConnectDisk(ctx context.Context, id, serverID types.ID) error {
+	return sacloud.NewDiskOp(c.caller).ConnectToServer(ctx, c.Zone, id, serverID)
+}
+
+// DisconnectDisk disconnects the disk from the server
+func (c *APIClient) DisconnectDisk(ctx context.Context, id types.ID) error {
+	return sacloud.NewDiskOp(c.caller).DisconnectFromServer(ctx, c.Zone, id)
+}
+
+func (c *APIClient) waitForDiskReady(ctx context.Context, id types.ID) error {
+	_, err := sacloud.WaiterForReady(func() (interface{}, error) {
+		return sacloud.NewDiskOp(c.caller).Read(ctx, c.Zone, id)
+	}).WaitForState(ctx)
+	return err
+}
@@ -0,0 +1,13 @@
+package sakuracloud
+
+import (
+	"context"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// ReadNote returns Note(startup script)
+func (c *APIClient) ReadNote(ctx context.Context, id types.ID) (*sacloud.Note, error) {
+	return sacloud.NewNoteOp(c.caller).Read(ctx, id)
+}
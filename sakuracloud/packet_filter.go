@@ -0,0 +1,31 @@
+package sakuracloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// CreatePacketFilter creates packet filter
+func (c *APIClient) CreatePacketFilter(ctx context.Context, name, description string, expressions []*sacloud.PacketFilterExpression) (string, error) {
+	pf, err := sacloud.NewPacketFilterOp(c.caller).Create(ctx, c.Zone, &sacloud.PacketFilterCreateRequest{
+		Name:        name,
+		Description: description,
+		Expression:  expressions,
+	})
+	if err != nil {
+		return "", err
+	}
+	return pf.ID.String(), nil
+}
+
+// DeletePacketFilter deletes packet filter
+func (c *APIClient) DeletePacketFilter(ctx context.Context, strID string) error {
+	id := types.StringID(strID)
+	if id.IsEmpty() {
+		return fmt.Errorf("PacketFilterID is invalid: %s", strID)
+	}
+	return sacloud.NewPacketFilterOp(c.caller).Delete(ctx, c.Zone, id)
+}
@@ -0,0 +1,106 @@
+package sakuracloud
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// シークレットの参照形式のプレフィックス
+//
+// 参照形式で指定された場合はconfig storeへ参照のみを保存し、値は利用時に都度読み込む
+const (
+	secretEnvPrefix     = "env:"     // env:<環境変数名>
+	secretFilePrefix    = "file:"    // file:<ファイルパス>
+	secretKeyringPrefix = "keyring:" // keyring:<サービス名>/<アカウント名>
+	secretCommandPrefix = "cmd:"     // cmd:<コマンド>、標準出力を値とする
+)
+
+// IsSecretReference 値がシークレットの参照形式であるか
+func IsSecretReference(v string) bool {
+	for _, prefix := range []string{secretEnvPrefix, secretFilePrefix, secretKeyringPrefix, secretCommandPrefix} {
+		if strings.HasPrefix(v, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecret 参照形式の値からシークレットを読み込む、参照形式でない場合は値をそのまま返す
+func ResolveSecret(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, secretEnvPrefix):
+		name := strings.TrimPrefix(v, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(v, secretFilePrefix):
+		path := strings.TrimPrefix(v, secretFilePrefix)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading secret from file %q is failed: %s", path, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case strings.HasPrefix(v, secretKeyringPrefix):
+		return readKeyring(strings.TrimPrefix(v, secretKeyringPrefix))
+	case strings.HasPrefix(v, secretCommandPrefix):
+		return runSecretCommand(strings.TrimPrefix(v, secretCommandPrefix))
+	}
+	return v, nil
+}
+
+// readKeyring OSのキーリング(macOS: Keychain、Linux: Secret Service)からシークレットを読み込む
+func readKeyring(ref string) (string, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("keyring reference must be in the form of %s<service>/<account>: %q", secretKeyringPrefix, ref)
+	}
+	service, account := parts[0], parts[1]
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", fmt.Errorf("keyring is not supported on %s, use %s or %s instead", runtime.GOOS, secretFilePrefix, secretCommandPrefix)
+	}
+	value, err := output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("reading secret from keyring %q is failed: %s", ref, err)
+	}
+	return value, nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	value, err := output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("reading secret from command %q is failed: %s", command, err)
+	}
+	return value, nil
+}
+
+func output(cmd *exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
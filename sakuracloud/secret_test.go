@@ -0,0 +1,61 @@
+package sakuracloud
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSecretReference(t *testing.T) {
+	for _, v := range []string{"env:NAME", "file:/path", "keyring:service/account", "cmd:echo"} {
+		assert.True(t, IsSecretReference(v), v)
+	}
+	for _, v := range []string{"", "token", "ENV:NAME", "environment"} {
+		assert.False(t, IsSecretReference(v), v)
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("SAKURACLOUD_TEST_SECRET", "from-env")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("from-file\n"), 0600))
+
+	cases := []struct {
+		name   string
+		value  string
+		expect string
+		err    string
+		unix   bool // sh -cで実行するコマンドを含む
+	}{
+		{name: "plain", value: "plain", expect: "plain"},
+		{name: "env", value: "env:SAKURACLOUD_TEST_SECRET", expect: "from-env"},
+		{name: "env not set", value: "env:SAKURACLOUD_TEST_NOT_SET", err: `environment variable "SAKURACLOUD_TEST_NOT_SET" is not set`},
+		{name: "file", value: "file:" + secretFile, expect: "from-file"},
+		{name: "file not exists", value: "file:" + secretFile + ".missing", err: "reading secret from file"},
+		{name: "keyring without account", value: "keyring:service", err: "keyring reference must be in the form of"},
+		{name: "keyring with empty service", value: "keyring:/account", err: "keyring reference must be in the form of"},
+		{name: "keyring with empty account", value: "keyring:service/", err: "keyring reference must be in the form of"},
+		{name: "cmd", value: "cmd:echo ' from-cmd '", expect: "from-cmd", unix: true},
+		{name: "cmd failure", value: "cmd:echo message >&2; exit 1", err: "message", unix: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.unix && runtime.GOOS == "windows" {
+				t.Skip("sh is not available on windows")
+			}
+			got, err := ResolveSecret(tc.value)
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, got)
+		})
+	}
+}
@@ -4,81 +4,132 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/sacloud/libsacloud/v2/helper/power"
+	"github.com/sacloud/libsacloud/v2/helper/query"
 	"github.com/sacloud/libsacloud/v2/sacloud"
 	"github.com/sacloud/libsacloud/v2/sacloud/types"
 )
 
 // State reads server state
-func (c *APIClient) State(strID string) (string, error) {
+func (c *APIClient) State(ctx context.Context, strID string) (types.EServerInstanceStatus, types.EAvailability, error) {
 	id := types.StringID(strID)
 	if id.IsEmpty() {
-		return "", fmt.Errorf("ServerID is invalid: %s", strID)
+		return "", "", fmt.Errorf("ServerID is invalid: %s", strID)
 	}
-	server, err := sacloud.NewServerOp(c.caller).Read(context.Background(), c.Zone, id)
+	server, err := sacloud.NewServerOp(c.caller).Read(ctx, c.Zone, id)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return string(server.InstanceStatus), nil
+	return server.InstanceStatus, server.Availability, nil
 }
 
 // PowerOn power on
-func (c *APIClient) PowerOn(strID string) error {
+func (c *APIClient) PowerOn(ctx context.Context, strID string) error {
 	id := types.StringID(strID)
 	if id.IsEmpty() {
 		return fmt.Errorf("ServerID is invalid: %s", strID)
 	}
 
-	return sacloud.NewServerOp(c.caller).Boot(context.Background(), c.Zone, id)
+	return sacloud.NewServerOp(c.caller).Boot(ctx, c.Zone, id)
 }
 
-// PowerOff power off
-func (c *APIClient) PowerOff(strID string) error {
+// PowerOff power off(ACPI shutdown), or force power off if force is true
+func (c *APIClient) PowerOff(ctx context.Context, strID string, force bool) error {
 	id := types.StringID(strID)
 	if id.IsEmpty() {
 		return fmt.Errorf("ServerID is invalid: %s", strID)
 	}
-	return sacloud.NewServerOp(c.caller).Shutdown(context.Background(), c.Zone, id, nil)
+	return sacloud.NewServerOp(c.caller).Shutdown(ctx, c.Zone, id, &sacloud.ShutdownOption{Force: force})
+}
+
+// Reset reset server
+func (c *APIClient) Reset(ctx context.Context, strID string) error {
+	id := types.StringID(strID)
+	if id.IsEmpty() {
+		return fmt.Errorf("ServerID is invalid: %s", strID)
+	}
+	return sacloud.NewServerOp(c.caller).Reset(ctx, c.Zone, id)
 }
 
 // GetIP get public ip address
-func (c *APIClient) GetIP(strID string) (string, error) {
+func (c *APIClient) GetIP(ctx context.Context, strID string) (string, error) {
 	id := types.StringID(strID)
 	if id.IsEmpty() {
 		return "", fmt.Errorf("ServerID is invalid: %s", strID)
 	}
-	server, err := sacloud.NewServerOp(c.caller).Read(context.Background(), c.Zone, id)
+	server, err := sacloud.NewServerOp(c.caller).Read(ctx, c.Zone, id)
 	if err != nil {
 		return "", err
 	}
 	return server.Interfaces[0].IPAddress, nil
 }
 
-// Delete delete server
-func (c *APIClient) Delete(strID string, strDisks []string) error {
-	id := types.StringID(strID)
-	if id.IsEmpty() {
-		return fmt.Errorf("ServerID is invalid: %s", strID)
+// DeleteWithDisks deletes server with connected disks
+func (c *APIClient) DeleteWithDisks(ctx context.Context, id types.ID, diskIDs []types.ID) error {
+	return sacloud.NewServerOp(c.caller).DeleteWithDisks(ctx, c.Zone, id, &sacloud.ServerDeleteWithDisksRequest{
+		IDs: diskIDs,
+	})
+}
+
+// IsExistsServer returns true if Server is exists
+func (c *APIClient) IsExistsServer(ctx context.Context, id types.ID) (bool, error) {
+	sv, err := sacloud.NewServerOp(c.caller).Read(ctx, c.Zone, id)
+	if err != nil {
+		if sacloud.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return sv != nil, nil
+}
+
+// ReadServer returns server info
+func (c *APIClient) ReadServer(ctx context.Context, id types.ID) (*sacloud.Server, error) {
+	sv, err := sacloud.NewServerOp(c.caller).Read(ctx, c.Zone, id)
+	if err != nil {
+		return nil, err
 	}
+	return sv, nil
+}
 
-	server, err := sacloud.NewServerOp(c.caller).Read(context.Background(), c.Zone, id)
+// ForceDelete shutdowns server forcibly and deletes it with connected disks
+func (c *APIClient) ForceDelete(ctx context.Context, id types.ID) error {
+	serverOp := sacloud.NewServerOp(c.caller)
+	server, err := serverOp.Read(ctx, c.Zone, id)
 	if err != nil {
-		return fmt.Errorf("reading server is failed: %s", id)
+		return err
 	}
+	if server.InstanceStatus.IsUp() {
+		if err := power.ShutdownServer(ctx, serverOp, c.Zone, id, true); err != nil {
+			return err
+		}
+	}
+
 	var disks []types.ID
 	for _, disk := range server.Disks {
 		disks = append(disks, disk.ID)
 	}
-
-	return sacloud.NewServerOp(c.caller).DeleteWithDisks(context.Background(), c.Zone, id, &sacloud.ServerDeleteWithDisksRequest{
+	return serverOp.DeleteWithDisks(ctx, c.Zone, id, &sacloud.ServerDeleteWithDisksRequest{
 		IDs: disks,
 	})
 }
 
-// ReadServer returns server info
-func (c *APIClient) ReadServer(ctx context.Context, id types.ID) (*sacloud.Server, error) {
-	sv, err := sacloud.NewServerOp(c.caller).Read(context.Background(), c.Zone, id)
+// DefaultUserName returns default admin user name from source archives/disks of the server, or empty if unknown
+func (c *APIClient) DefaultUserName(ctx context.Context, id types.ID) (string, error) {
+	return query.ServerDefaultUserName(ctx, c.Zone, query.NewServerSourceReader(c.caller), id)
+}
+
+// ChangePlan changes the plan of the server and returns new server ID
+func (c *APIClient) ChangePlan(ctx context.Context, id types.ID, core, memory, gpu int, commitment types.ECommitment, generation types.EPlanGeneration) (types.ID, error) {
+	server, err := sacloud.NewServerOp(c.caller).ChangePlan(ctx, c.Zone, id, &sacloud.ServerChangePlanRequest{
+		CPU:                  core,
+		MemoryMB:             memory * 1024,
+		GPU:                  gpu,
+		ServerPlanCommitment: commitment,
+		ServerPlanGeneration: generation,
+	})
 	if err != nil {
-		return nil, err
+		return types.ID(0), err
 	}
-	return sv, nil
+	return server.ID, nil
 }
@@ -0,0 +1,25 @@
+package sakuracloud
+
+import (
+	"context"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+)
+
+// FindServiceClasses returns service classes(price list) of the zone
+func (c *APIClient) FindServiceClasses(ctx context.Context) ([]*sacloud.ServiceClass, error) {
+	searched, err := sacloud.NewServiceClassOp(c.caller).Find(ctx, c.Zone, &sacloud.FindCondition{})
+	if err != nil {
+		return nil, err
+	}
+
+	var classes []*sacloud.ServiceClass
+	for _, class := range searched.ServiceClasses {
+		// ゾーンごとの料金が返される場合は対象ゾーンのもののみとする
+		if class.Price != nil && class.Price.Zone != "" && class.Price.Zone != c.Zone {
+			continue
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
@@ -0,0 +1,43 @@
+package sakuracloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// IsExistsSSHKey returns true if SSHKey is exists
+func (c *APIClient) IsExistsSSHKey(ctx context.Context, id types.ID) (bool, error) {
+	key, err := sacloud.NewSSHKeyOp(c.caller).Read(ctx, id)
+	if err != nil {
+		if sacloud.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return key != nil, nil
+}
+
+// CreateSSHKey registers public key as SSHKey
+func (c *APIClient) CreateSSHKey(ctx context.Context, name, description, publicKey string) (string, error) {
+	key, err := sacloud.NewSSHKeyOp(c.caller).Create(ctx, &sacloud.SSHKeyCreateRequest{
+		Name:        name,
+		Description: description,
+		PublicKey:   publicKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	return key.ID.String(), nil
+}
+
+// DeleteSSHKey deletes SSHKey
+func (c *APIClient) DeleteSSHKey(ctx context.Context, strID string) error {
+	id := types.StringID(strID)
+	if id.IsEmpty() {
+		return fmt.Errorf("SSHKeyID is invalid: %s", strID)
+	}
+	return sacloud.NewSSHKeyOp(c.caller).Delete(ctx, id)
+}
@@ -0,0 +1,52 @@
+package sakuracloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sacloud/libsacloud/v2/sacloud"
+	"github.com/sacloud/libsacloud/v2/sacloud/types"
+)
+
+// FindVPCRouterPortForwarding returns global address and port which is forwarded to privateAddress:privatePort(tcp)
+func (c *APIClient) FindVPCRouterPortForwarding(ctx context.Context, strID string, privateAddress string, privatePort int) (string, int, error) {
+	id := types.StringID(strID)
+	if id.IsEmpty() {
+		return "", 0, fmt.Errorf("VPCRouterID is invalid: %s", strID)
+	}
+	router, err := sacloud.NewVPCRouterOp(c.caller).Read(ctx, c.Zone, id)
+	if err != nil {
+		return "", 0, err
+	}
+
+	globalAddress := vpcRouterGlobalAddress(router)
+	if globalAddress == "" {
+		return "", 0, fmt.Errorf("VPCRouter[%s] does not have global address", strID)
+	}
+
+	if router.Settings != nil {
+		for _, pf := range router.Settings.PortForwarding {
+			if pf.Protocol != types.VPCRouterPortForwardingProtocols.TCP {
+				continue
+			}
+			if pf.PrivateAddress == privateAddress && pf.PrivatePort.Int() == privatePort {
+				return globalAddress, pf.GlobalPort.Int(), nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("VPCRouter[%s] does not have port forwarding to %s:%d/tcp", strID, privateAddress, privatePort)
+}
+
+func vpcRouterGlobalAddress(router *sacloud.VPCRouter) string {
+	if router.Settings != nil {
+		for _, iface := range router.Settings.Interfaces {
+			if iface.Index == 0 && iface.VirtualIPAddress != "" {
+				return iface.VirtualIPAddress
+			}
+		}
+	}
+	if len(router.Interfaces) > 0 {
+		return router.Interfaces[0].IPAddress
+	}
+	return ""
+}
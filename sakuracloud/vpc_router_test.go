@@ -0,0 +1,51 @@
+package sakuracloud
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVPCRouterResponse = `{
+  "Appliance": {
+    "ID": "123456789012",
+    "Class": "vpcrouter",
+    "Interfaces": [{"IPAddress": "203.0.113.11"}],
+    "Settings": {
+      "Router": {
+        "PortForwarding": {
+          "Config": [
+            {"Protocol": "tcp", "GlobalPort": "10022", "PrivateAddress": "192.168.0.11", "PrivatePort": "22"},
+            {"Protocol": "udp", "GlobalPort": "12376", "PrivateAddress": "192.168.0.11", "PrivatePort": "2376"}
+          ]
+        }
+      }
+    }
+  }
+}`
+
+func TestAPIClient_FindVPCRouterPortForwarding(t *testing.T) {
+	server := newTestAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testVPCRouterResponse)) // nolint
+	})
+
+	client := NewAPIClient("token", "secret", "is1b")
+	client.APIRootURL = server.URL
+	require.NoError(t, client.ValidateClientConfig())
+
+	host, port, err := client.FindVPCRouterPortForwarding(context.Background(), "123456789012", "192.168.0.11", 22)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.11", host)
+	assert.Equal(t, 10022, port)
+
+	// UDPのポートフォワードは対象外
+	_, _, err = client.FindVPCRouterPortForwarding(context.Background(), "123456789012", "192.168.0.11", 2376)
+	assert.Error(t, err)
+
+	_, _, err = client.FindVPCRouterPortForwarding(context.Background(), "invalid", "192.168.0.11", 22)
+	assert.Error(t, err)
+}